@@ -13,3 +13,23 @@ type AlreadyVisited struct {
 func (e AlreadyVisited) Error() string {
 	return fmt.Sprintf("request to %s filtered, already visited", e.URL.String())
 }
+
+// LoggedOut is thrown when a request's session is still logged out after logging it in again.
+type LoggedOut struct {
+	URL     url.URL
+	Session string
+}
+
+func (e LoggedOut) Error() string {
+	return fmt.Sprintf("request to %s failed, session %q logged out", e.URL.String(), e.Session)
+}
+
+// TooManyRedirects is thrown when a request is redirected more often than the spider's MaxRedirects.
+type TooManyRedirects struct {
+	URL url.URL
+	Max int
+}
+
+func (e TooManyRedirects) Error() string {
+	return fmt.Sprintf("request to %s failed, stopped after %d redirects", e.URL.String(), e.Max)
+}
@@ -1,8 +1,21 @@
 module github.com/KillianMeersman/wander
 
-go 1.13
+go 1.22
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/antchfx/htmlquery v1.2.3
+	github.com/antchfx/jsonquery v1.1.4
+	github.com/antchfx/xmlquery v1.3.5
 	github.com/go-redis/redis/v7 v7.2.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
+)
+
+require (
+	github.com/andybalholm/cascadia v1.0.0 // indirect
+	github.com/antchfx/xpath v1.1.10 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 )
@@ -25,3 +25,21 @@ type ForbiddenDomain struct {
 func (e ForbiddenDomain) Error() string {
 	return fmt.Sprintf("request to %s filtered, not in allowed domains", e.URL.String())
 }
+
+// InvalidRetryAfter indicates a Retry-After header could not be parsed.
+type InvalidRetryAfter struct {
+	Value string
+}
+
+func (e InvalidRetryAfter) Error() string {
+	return fmt.Sprintf("invalid Retry-After value %q", e.Value)
+}
+
+// ThrottleUnavailable indicates a shared throttle could not be reached, requests are throttled locally instead.
+type ThrottleUnavailable struct {
+	Err error
+}
+
+func (e ThrottleUnavailable) Error() string {
+	return fmt.Sprintf("shared throttle unavailable, throttling locally: %s", e.Err)
+}
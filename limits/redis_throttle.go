@@ -0,0 +1,209 @@
+package limits
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// reserveScript reserves the next request slot for a throttle and returns the amount of microseconds to wait for it.
+// The slot is the latest of the current time, the throttle's next free slot and any wait times set for the host or globally.
+// Redis server time is used so processes with skewed clocks still agree on the schedule.
+//
+// KEYS[1] next slot for the throttle, KEYS[2] wait time for the host, KEYS[3] global wait time.
+// ARGV[1] interval in microseconds.
+var reserveScript = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local interval = tonumber(ARGV[1])
+local slot = now
+for i = 1, 3 do
+	local value = tonumber(redis.call('GET', KEYS[i]))
+	if value and value > slot then
+		slot = value
+	end
+end
+
+if interval > 0 then
+	local expiry = math.ceil((slot + interval - now) / 1000) + 1
+	redis.call('SET', KEYS[1], slot + interval, 'PX', expiry)
+end
+return slot - now
+`)
+
+// waitScript sets a wait time, an existing wait time is only replaced if it ends sooner.
+//
+// KEYS[1] wait time key.
+// ARGV[1] wait time in microseconds.
+var waitScript = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local deadline = now + tonumber(ARGV[1])
+local current = tonumber(redis.call('GET', KEYS[1]))
+if not current or deadline > current then
+	redis.call('SET', KEYS[1], deadline, 'PX', math.ceil(tonumber(ARGV[1]) / 1000) + 1)
+end
+return 0
+`)
+
+// RedisThrottle throttles requests per host, sharing its state between processes through Redis.
+// Spiders sharing a RedisThrottle will make requests to a host at the configured aggregate rate,
+// and back off together when a host returns a Retry-After header.
+//
+// Domain throttles are resolved locally like in a ThrottleCollection, only their schedules are shared.
+// Each process should therefore set the same domain throttles.
+//
+// When Redis can't be reached, requests are throttled by a local ThrottleCollection with the same delays and wait times,
+// and ThrottleUnavailable is passed to the error callback, see OnError.
+type RedisThrottle struct {
+	client   *redis.Client
+	key      string
+	interval time.Duration
+	// local holds the domain throttles, and throttles requests when Redis can't be reached
+	local     ThrottleCollection
+	errorFunc func(error)
+	lock      sync.RWMutex
+}
+
+// NewRedisThrottle instantiates a new Redis throttle.
+// Hosts without a domain throttle are throttled with the default delay, a delay of zero or less disables this.
+// Domain throttles are not stored in Redis, every process sharing the throttle must set the same domain throttles.
+func NewRedisThrottle(host string, port int, password, key string, db int, delay time.Duration) (*RedisThrottle, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+		DB:       db,
+	})
+
+	_, err := client.Ping().Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisThrottle{
+		client:    client,
+		key:       key,
+		interval:  delay,
+		local:     NewThrottleCollection(nil),
+		errorFunc: func(err error) {},
+	}, nil
+}
+
+// OnError is called when Redis can't be reached, with a ThrottleUnavailable error.
+// This will overwrite any previous callbacks set by this method.
+func (t *RedisThrottle) OnError(f func(err error)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.errorFunc = f
+}
+
+// Wait blocks until the next request slot of the most appropriate throttle.
+// Falls back to the local throttles if Redis cannot be reached.
+func (t *RedisThrottle) Wait(req *http.Request) {
+	name, interval := req.URL.Host, t.interval
+	throttle := t.local.getDomainThrottle(req)
+	if throttle != nil {
+		name, interval = throttle.key(), throttle.getInterval()
+	}
+
+	keys := []string{
+		t.hostKey("next", name),
+		t.hostKey("wait", req.URL.Host),
+		t.globalWaitKey(),
+	}
+	wait, err := reserveScript.Run(t.client, keys, microseconds(interval)).Int64()
+	if err != nil {
+		t.handleError(err)
+		if throttle == nil && interval > 0 {
+			// hosts without a domain throttle have their own schedule, like in Redis
+			t.local.SetDomainThrottle(NewDomainThrottle(req.URL.Host, interval))
+		}
+		t.local.Wait(req)
+		return
+	}
+	time.Sleep(time.Duration(wait) * time.Microsecond)
+}
+
+// Applies returns true, the Redis throttle applies to all requests.
+func (t *RedisThrottle) Applies(_ *http.Request) bool {
+	return true
+}
+
+// SetWaitTime makes requests to all hosts block for a duration.
+func (t *RedisThrottle) SetWaitTime(waitTime time.Duration) {
+	t.local.SetWaitTime(waitTime)
+	t.setWaitTime(t.globalWaitKey(), waitTime)
+}
+
+// SetHostWaitTime makes requests to a single host block for a duration.
+func (t *RedisThrottle) SetHostWaitTime(host string, waitTime time.Duration) {
+	t.local.SetHostWaitTime(host, waitTime)
+	t.setWaitTime(t.hostKey("wait", host), waitTime)
+}
+
+// SetDomainThrottle sets a domain throttle, see ThrottleCollection.SetDomainThrottle.
+// Only the domain, path prefix and delay of the throttle are used, its schedule is kept in Redis.
+func (t *RedisThrottle) SetDomainThrottle(throttle *DomainThrottle) {
+	t.local.SetDomainThrottle(throttle)
+}
+
+// Clear removes all throttling state from Redis.
+// Keys are found with SCAN, so Redis is not blocked while they are collected.
+func (t *RedisThrottle) Clear() error {
+	keys := make([]string, 0)
+	iter := t.client.Scan(0, t.key+":*", 100).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) < 1 {
+		return nil
+	}
+	return t.client.Del(keys...).Err()
+}
+
+// Close closes the connection to Redis, requests are throttled locally afterwards.
+func (t *RedisThrottle) Close() error {
+	return t.client.Close()
+}
+
+func (t *RedisThrottle) setWaitTime(key string, waitTime time.Duration) {
+	if waitTime <= 0 {
+		return
+	}
+	err := waitScript.Run(t.client, []string{key}, microseconds(waitTime)).Err()
+	if err != nil {
+		t.handleError(err)
+	}
+}
+
+func (t *RedisThrottle) handleError(err error) {
+	t.lock.RLock()
+	errorFunc := t.errorFunc
+	t.lock.RUnlock()
+	errorFunc(ThrottleUnavailable{Err: err})
+}
+
+func (t *RedisThrottle) hostKey(kind, host string) string {
+	return fmt.Sprintf("%s:%s:%s", t.key, kind, host)
+}
+
+func (t *RedisThrottle) globalWaitKey() string {
+	return t.key + ":wait"
+}
+
+func microseconds(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return int64(d / time.Microsecond)
+}
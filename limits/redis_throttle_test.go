@@ -0,0 +1,64 @@
+package limits_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits"
+)
+
+func TestRedisThrottleShared(t *testing.T) {
+	a, err := limits.NewRedisThrottle("localhost", 6379, "", "wander_throttle", 1, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Clear()
+	b, err := limits.NewRedisThrottle("localhost", 6379, "", "wander_throttle", 1, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "localhost:8080"}}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		a.Wait(req)
+		b.Wait(req)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("10 requests made in %s, expected at least 900ms", elapsed)
+	}
+
+	a.SetHostWaitTime("localhost:8080", 500*time.Millisecond)
+	start = time.Now()
+	b.Wait(req)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("wait time not shared, waited %s", elapsed)
+	}
+}
+
+func TestRedisThrottleFallback(t *testing.T) {
+	throttle, err := limits.NewRedisThrottle("localhost", 6379, "", "wander_throttle_fallback", 1, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 10)
+	throttle.OnError(func(err error) {
+		errs <- err
+	})
+	throttle.Clear()
+	throttle.Close()
+
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "localhost:8080"}}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		throttle.Wait(req)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("3 requests made in %s without Redis, expected at least 200ms", elapsed)
+	}
+	if _, ok := (<-errs).(limits.ThrottleUnavailable); !ok {
+		t.Fatal("expected ThrottleUnavailable")
+	}
+}
@@ -0,0 +1,39 @@
+package limits
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRetryAfter parses a Retry-After header value and returns the duration to wait, relative to now.
+// Both the HTTP-date and delta-seconds forms of RFC 9110 are supported.
+// Dates in the past result in a zero duration.
+// Returns InvalidRetryAfter if the value could not be parsed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	// delta-seconds = 1*DIGIT
+	if len(value) > 0 && strings.Trim(value, "0123456789") == "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds > int64(maxDuration/time.Second) {
+			// too large to represent, wait as long as possible
+			return maxDuration, nil
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	// HTTP-date, accepts IMF-fixdate as well as the obsolete RFC 850 and asctime formats
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, InvalidRetryAfter{Value: value}
+	}
+	wait := date.Sub(now)
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+const maxDuration = time.Duration(1<<63 - 1)
@@ -0,0 +1,41 @@
+package limits_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, time.October, 21, 7, 28, 0, 0, time.UTC)
+
+	cases := []struct {
+		value string
+		wait  time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{" 5 ", 5 * time.Second},
+		{"Wed, 21 Oct 2015 07:30:00 GMT", 2 * time.Minute},
+		{"Wednesday, 21-Oct-15 07:29:00 GMT", time.Minute},
+		{"Wed Oct 21 07:28:30 2015", 30 * time.Second},
+		{"Wed, 21 Oct 2015 07:00:00 GMT", 0},
+	}
+	for _, c := range cases {
+		wait, err := limits.ParseRetryAfter(c.value, now)
+		if err != nil {
+			t.Fatalf("%q: %v", c.value, err)
+		}
+		if wait != c.wait {
+			t.Fatalf("%q: expected %s, got %s", c.value, c.wait, wait)
+		}
+	}
+
+	for _, value := range []string{"", "-1", "1.5", "soon", "2015-10-21T07:30:00Z"} {
+		_, err := limits.ParseRetryAfter(value, now)
+		if _, ok := err.(limits.InvalidRetryAfter); !ok {
+			t.Fatalf("%q: expected InvalidRetryAfter, got %v", value, err)
+		}
+	}
+}
@@ -23,3 +23,41 @@ type RobotDenied struct {
 func (e RobotDenied) Error() string {
 	return fmt.Sprintf("request for %s denied by robots.txt", e.URL.String())
 }
+
+// SitemapLimitExceeded indicates a sitemap exceeds the maximum amount of locations or size.
+type SitemapLimitExceeded struct {
+	Limit string
+}
+
+func (e SitemapLimitExceeded) Error() string {
+	return fmt.Sprintf("sitemap exceeds limit of %s", e.Limit)
+}
+
+// SitemapError indicates a sitemap could not be read.
+type SitemapError struct {
+	URL string
+	Err error
+}
+
+func (e SitemapError) Error() string {
+	return fmt.Sprintf("sitemap %s could not be read: %s", e.URL, e.Err)
+}
+
+// SitemapErrors holds the errors of all sitemaps that could not be read while walking sitemaps.
+type SitemapErrors []SitemapError
+
+func (e SitemapErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d sitemaps could not be read, first error: %s", len(e), e[0].Error())
+}
+
+// PageNoFollow indicates a link was not followed because its page has a nofollow directive.
+type PageNoFollow struct {
+	URL url.URL
+}
+
+func (e PageNoFollow) Error() string {
+	return fmt.Sprintf("links on %s not followed, page has a nofollow directive", e.URL.String())
+}
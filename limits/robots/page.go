@@ -0,0 +1,153 @@
+package robots
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageRules holds the robots directives of a single page, set by <meta name="robots"> elements and X-Robots-Tag headers.
+type PageRules struct {
+	// NoIndex indicates the page should not be indexed.
+	NoIndex bool
+	// NoFollow indicates the links on the page should not be followed.
+	NoFollow bool
+	// NoArchive indicates no cached copy of the page should be kept.
+	NoArchive bool
+	// NoSnippet indicates no snippets of the page should be shown.
+	NoSnippet bool
+	// NoImageIndex indicates the images on the page should not be indexed.
+	NoImageIndex bool
+	// NoTranslate indicates no translations of the page should be offered.
+	NoTranslate bool
+	// UnavailableAfter is the time after which the page should not be indexed, zero if not set.
+	UnavailableAfter time.Time
+}
+
+// valueDirectives are the directives taking a value after a colon, used to tell them apart from user agent prefixes.
+var valueDirectives = map[string]struct{}{
+	"unavailable_after": {},
+	"max-snippet":       {},
+	"max-image-preview": {},
+	"max-video-preview": {},
+}
+
+// unavailableAfterLayouts are the date formats accepted for the unavailable_after directive.
+var unavailableAfterLayouts = []string{
+	time.RFC850,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC3339,
+	"2006-01-02",
+	"02 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 MST",
+}
+
+// NewPageRules parses the robots directives of a page that apply to the user agent.
+// Directives in X-Robots-Tag headers and <meta> elements named "robots" apply to all user agents,
+// those prefixed with or named after the user agent's product token only apply to that user agent.
+// The directives that apply are combined, the document may be nil if the page is not HTML.
+func NewPageRules(userAgent string, header http.Header, doc *goquery.Document) *PageRules {
+	rules := &PageRules{}
+	token := productToken(userAgent)
+
+	for _, value := range header[http.CanonicalHeaderKey("X-Robots-Tag")] {
+		if prefix, directives, ok := splitUserAgentPrefix(value); ok {
+			if productToken(prefix) == token {
+				rules.AddDirectives(directives)
+			}
+			continue
+		}
+		rules.AddDirectives(value)
+	}
+
+	if doc != nil {
+		doc.Find("meta[name][content]").Each(func(_ int, meta *goquery.Selection) {
+			name, _ := meta.Attr("name")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "robots" || name == token {
+				content, _ := meta.Attr("content")
+				rules.AddDirectives(content)
+			}
+		})
+	}
+	return rules
+}
+
+// AddDirectives adds a comma-separated list of directives to the rules, directives are case-insensitive.
+// Unknown directives are ignored.
+func (r *PageRules) AddDirectives(value string) {
+	directives := strings.Split(value, ",")
+	for i := 0; i < len(directives); i++ {
+		name, parameter := directives[i], ""
+		if j := strings.IndexByte(name, ':'); j > -1 {
+			name, parameter = name[:j], strings.TrimSpace(name[j+1:])
+		}
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "noindex":
+			r.NoIndex = true
+		case "nofollow":
+			r.NoFollow = true
+		case "none":
+			r.NoIndex = true
+			r.NoFollow = true
+		case "noarchive", "nocache":
+			r.NoArchive = true
+		case "nosnippet":
+			r.NoSnippet = true
+		case "noimageindex":
+			r.NoImageIndex = true
+		case "notranslate":
+			r.NoTranslate = true
+		case "unavailable_after":
+			t, ok := parseUnavailableAfter(parameter)
+			// dates such as "Monday, 02-Jan-06 15:04:05 MST" contain a comma
+			if !ok && i+1 < len(directives) {
+				if t, ok = parseUnavailableAfter(parameter + "," + directives[i+1]); ok {
+					i++
+				}
+			}
+			if ok && (r.UnavailableAfter.IsZero() || t.Before(r.UnavailableAfter)) {
+				r.UnavailableAfter = t
+			}
+		}
+	}
+}
+
+func parseUnavailableAfter(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range unavailableAfterLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Indexable returns true if the page may be indexed at the given time.
+func (r *PageRules) Indexable(now time.Time) bool {
+	if r.NoIndex {
+		return false
+	}
+	return r.UnavailableAfter.IsZero() || now.Before(r.UnavailableAfter)
+}
+
+// splitUserAgentPrefix splits an X-Robots-Tag value of the form "googlebot: noindex" into the user agent and its directives.
+// Returns false if the value has no user agent prefix.
+func splitUserAgentPrefix(value string) (string, string, bool) {
+	i := strings.IndexByte(value, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	prefix := strings.TrimSpace(value[:i])
+	if strings.ContainsAny(prefix, ", ") {
+		return "", "", false
+	}
+	if _, ok := valueDirectives[strings.ToLower(prefix)]; ok {
+		return "", "", false
+	}
+	return prefix, value[i+1:], true
+}
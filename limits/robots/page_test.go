@@ -0,0 +1,48 @@
+package robots_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPageRules(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+<meta name="robots" content="NoArchive">
+<meta name="wander" content="noindex">
+<meta name="otherbot" content="nofollow">
+</head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Add("X-Robots-Tag", "nosnippet, unavailable_after: Monday, 21-Sep-20 15:00:00 UTC")
+	header.Add("X-Robots-Tag", "wander: noimageindex")
+	header.Add("X-Robots-Tag", "otherbot: none")
+
+	rules := robots.NewPageRules("Wander/0.1", header, doc)
+	if !rules.NoIndex || !rules.NoArchive || !rules.NoSnippet || !rules.NoImageIndex {
+		t.Fatalf("directives for the user agent not applied: %+v", rules)
+	}
+	if rules.NoFollow {
+		t.Fatal("directives for another user agent applied")
+	}
+	if !rules.UnavailableAfter.Equal(time.Date(2020, 9, 21, 15, 0, 0, 0, time.UTC)) {
+		t.Fatalf("invalid unavailable_after %s", rules.UnavailableAfter)
+	}
+
+	rules = robots.NewPageRules("otherbot", http.Header{}, doc)
+	if !rules.NoFollow || rules.NoIndex {
+		t.Fatalf("invalid directives %+v", rules)
+	}
+
+	rules = &robots.PageRules{}
+	rules.AddDirectives("unavailable_after: 2020-09-21")
+	if rules.Indexable(time.Date(2020, 9, 22, 0, 0, 0, 0, time.UTC)) || !rules.Indexable(time.Date(2020, 9, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("unavailable_after not applied")
+	}
+}
@@ -0,0 +1,146 @@
+package robots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// storedRobotFile is the representation of a robot file in Redis.
+// The raw text is stored instead of the parsed rules and parsed again when it is retrieved.
+type storedRobotFile struct {
+	Status     RobotFileStatus `json:"status"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error,omitempty"`
+	Text       string          `json:"text"`
+}
+
+// RedisRobotRules holds the robot exclusions for multiple hosts in Redis.
+// Spiders sharing a RedisRobotRules download each robots.txt file once per TTL.
+// Parsed files are also cached locally until they expire in Redis, Redis is only read for files not cached locally.
+type RedisRobotRules struct {
+	client *redis.Client
+	key    string
+	ttl    time.Duration
+	local  *LocalRobotRules
+}
+
+// NewRedisRobotRules instantiates a new Redis robot limit cache, caching files for the given duration.
+func NewRedisRobotRules(host string, port int, password, key string, db int, ttl time.Duration) (*RedisRobotRules, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+		DB:       db,
+	})
+
+	_, err := client.Ping().Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRobotRules{
+		client: client,
+		key:    key,
+		ttl:    ttl,
+		local:  NewRobotRulesWithTTL(ttl),
+	}, nil
+}
+
+// Allowed returns true if the userAgent is allowed to access the given path on the given domain.
+// Returns error if no robot file is cached for the given domain.
+func (c *RedisRobotRules) Allowed(userAgent string, url *url.URL) (bool, error) {
+	limits, err := c.GetRulesForHost(url.Host)
+	if err != nil {
+		return false, err
+	}
+	return limits.Allowed(userAgent, url.RequestURI()), nil
+}
+
+// GetRulesForHost gets the rules for a host. Returns an error when no limits are cached for the given host or they have expired.
+func (c *RedisRobotRules) GetRulesForHost(host string) (*RobotFile, error) {
+	if robotFile, err := c.local.GetRulesForHost(host); err == nil {
+		return robotFile, nil
+	}
+
+	pipe := c.client.TxPipeline()
+	get := pipe.Get(c.hostKey(host))
+	pttl := pipe.PTTL(c.hostKey(host))
+	_, err := pipe.Exec()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("No limits found for domain %s", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	val := get.Val()
+
+	stored := storedRobotFile{}
+	err = json.Unmarshal([]byte(val), &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	robotFile, err := NewRobotFileFromReader(strings.NewReader(stored.Text))
+	if err != nil {
+		return nil, err
+	}
+	robotFile.status = stored.Status
+	robotFile.statusCode = stored.StatusCode
+	if stored.Error != "" {
+		robotFile.fetchErr = errors.New(stored.Error)
+	}
+	if ttl := pttl.Val(); ttl > 0 {
+		c.local.add(robotFile, host, time.Now().Add(ttl))
+	}
+	return robotFile, nil
+}
+
+// AddLimits adds or replaces the limits for a host.
+func (c *RedisRobotRules) AddLimits(robotFile *RobotFile, host string) error {
+	now := time.Now()
+	ttl := expiryTime(robotFile, now, c.ttl).Sub(now)
+	if ttl <= 0 {
+		return nil
+	}
+
+	stored := storedRobotFile{
+		Status:     robotFile.status,
+		StatusCode: robotFile.statusCode,
+		Text:       robotFile.text,
+	}
+	if robotFile.fetchErr != nil {
+		stored.Error = robotFile.fetchErr.Error()
+	}
+	val, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	err = c.client.Set(c.hostKey(host), val, ttl).Err()
+	if err != nil {
+		return err
+	}
+	c.local.add(robotFile, host, now.Add(ttl))
+	return nil
+}
+
+// Clear removes all cached robot files.
+func (c *RedisRobotRules) Clear() error {
+	c.local.Clear()
+	keys, err := c.client.Keys(c.key + ":*").Result()
+	if err != nil {
+		return err
+	}
+	if len(keys) < 1 {
+		return nil
+	}
+	return c.client.Del(keys...).Err()
+}
+
+func (c *RedisRobotRules) hostKey(host string) string {
+	return fmt.Sprintf("%s:%s", c.key, host)
+}
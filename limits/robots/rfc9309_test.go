@@ -0,0 +1,295 @@
+package robots_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+)
+
+// The cases below are ported from the test suite of Google's reference robots.txt parser,
+// https://github.com/google/robotstxt, which accompanies RFC 9309.
+
+type robotsCase struct {
+	userAgent string
+	url       string
+	allowed   bool
+}
+
+func checkRobots(t *testing.T, name, robotsTxt string, cases ...robotsCase) {
+	t.Helper()
+
+	robotFile, err := robots.NewRobotFileFromReader(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if allowed := robotFile.Allowed(c.userAgent, u.RequestURI()); allowed != c.allowed {
+			t.Errorf("%s: %s accessing %s, expected allowed %t, got %t", name, c.userAgent, c.url, c.allowed, allowed)
+		}
+	}
+}
+
+func TestRFC9309SystemTest(t *testing.T) {
+	robotsTxt := "user-agent: FooBot\ndisallow: /\n"
+
+	checkRobots(t, "empty robots.txt", "",
+		robotsCase{"FooBot", "", true},
+		robotsCase{"FooBot", "http://foo.bar/", true},
+	)
+	checkRobots(t, "empty url", robotsTxt,
+		robotsCase{"FooBot", "", false},
+	)
+}
+
+func TestRFC9309LineSyntax(t *testing.T) {
+	checkRobots(t, "correct", "user-agent: FooBot\ndisallow: /\n",
+		robotsCase{"FooBot", "http://foo.bar/x/y", false},
+	)
+	checkRobots(t, "incorrect", "foo: FooBot\nbar: /\n",
+		robotsCase{"FooBot", "http://foo.bar/x/y", true},
+	)
+	checkRobots(t, "missing colon accepted", "user-agent FooBot\ndisallow /\n",
+		robotsCase{"FooBot", "http://foo.bar/x/y", false},
+	)
+}
+
+func TestRFC9309Groups(t *testing.T) {
+	robotsTxt := `allow: /foo/bar/
+
+user-agent: FooBot
+disallow: /
+allow: /x/
+user-agent: BarBot
+disallow: /
+allow: /y/
+
+
+allow: /w/
+user-agent: BazBot
+
+user-agent: FooBot
+allow: /z/
+disallow: /
+`
+
+	checkRobots(t, "groups", robotsTxt,
+		robotsCase{"FooBot", "http://foo.bar/x/b", true},
+		robotsCase{"FooBot", "http://foo.bar/z/d", true},
+		robotsCase{"FooBot", "http://foo.bar/y/c", false},
+		robotsCase{"BarBot", "http://foo.bar/y/c", true},
+		robotsCase{"BarBot", "http://foo.bar/w/a", true},
+		robotsCase{"BarBot", "http://foo.bar/z/d", false},
+		robotsCase{"BazBot", "http://foo.bar/z/d", true},
+		// lines with rules outside groups are ignored
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/", false},
+		robotsCase{"BarBot", "http://foo.bar/foo/bar/", false},
+		robotsCase{"BazBot", "http://foo.bar/foo/bar/", false},
+	)
+}
+
+func TestRFC9309GroupsOtherRules(t *testing.T) {
+	checkRobots(t, "sitemap in group", `User-agent: BarBot
+Sitemap: https://foo.bar/sitemap
+User-agent: *
+Disallow: /
+`,
+		robotsCase{"FooBot", "http://foo.bar/", false},
+		robotsCase{"BarBot", "http://foo.bar/", false},
+	)
+
+	checkRobots(t, "unknown line in group", `User-agent: FooBot
+Invalid-Unknown-Line: unknown
+User-agent: *
+Disallow: /
+`,
+		robotsCase{"FooBot", "http://foo.bar/", false},
+		robotsCase{"BarBot", "http://foo.bar/", false},
+	)
+}
+
+func TestRFC9309DirectivesCaseInsensitive(t *testing.T) {
+	upper := "USER-AGENT: FooBot\nALLOW: /x/\nDISALLOW: /\n"
+	lower := "user-agent: FooBot\nallow: /x/\ndisallow: /\n"
+	camel := "uSeR-aGeNt: FooBot\nAlLoW: /x/\ndIsAlLoW: /\n"
+
+	for name, robotsTxt := range map[string]string{"upper": upper, "lower": lower, "camel": camel} {
+		checkRobots(t, name, robotsTxt,
+			robotsCase{"FooBot", "http://foo.bar/x/y", true},
+			robotsCase{"FooBot", "http://foo.bar/a/b", false},
+		)
+	}
+}
+
+func TestRFC9309UserAgentCaseInsensitive(t *testing.T) {
+	upper := "User-Agent: FOO BAR\nAllow: /x/\nDisallow: /\n"
+	lower := "User-Agent: foo bar\nAllow: /x/\nDisallow: /\n"
+	camel := "User-Agent: FoO bAr\nAllow: /x/\nDisallow: /\n"
+
+	for name, robotsTxt := range map[string]string{"upper": upper, "lower": lower, "camel": camel} {
+		checkRobots(t, name, robotsTxt,
+			robotsCase{"Foo", "http://foo.bar/x/y", true},
+			robotsCase{"Foo", "http://foo.bar/a/b", false},
+			robotsCase{"foo", "http://foo.bar/x/y", true},
+			robotsCase{"foo", "http://foo.bar/a/b", false},
+		)
+	}
+}
+
+func TestRFC9309ProductToken(t *testing.T) {
+	robotsTxt := "User-Agent: *\nDisallow: /\nUser-Agent: Foo Bar\nAllow: /x/\nDisallow: /\n"
+
+	checkRobots(t, "product token", robotsTxt,
+		robotsCase{"Foo", "http://foo.bar/x/y", true},
+		robotsCase{"Foo/2.1 (+http://foo.bar/bot.html)", "http://foo.bar/x/y", true},
+		robotsCase{"Bar", "http://foo.bar/x/y", false},
+	)
+
+	checkRobots(t, "versioned group", "User-Agent: Googlebot/2.1\nDisallow: /\n",
+		robotsCase{"googlebot", "http://foo.bar/x/y", false},
+	)
+}
+
+func TestRFC9309GlobalGroups(t *testing.T) {
+	global := "user-agent: *\nallow: /\nuser-agent: FooBot\ndisallow: /\n"
+	specific := "user-agent: FooBot\nallow: /\nuser-agent: BarBot\ndisallow: /\nuser-agent: BazBot\ndisallow: /\n"
+
+	checkRobots(t, "empty", "",
+		robotsCase{"FooBot", "http://foo.bar/x/y", true},
+	)
+	checkRobots(t, "global", global,
+		robotsCase{"FooBot", "http://foo.bar/x/y", false},
+		robotsCase{"BarBot", "http://foo.bar/x/y", true},
+	)
+	checkRobots(t, "only specific", specific,
+		robotsCase{"QuxBot", "http://foo.bar/x/y", true},
+	)
+}
+
+func TestRFC9309ValueCaseSensitive(t *testing.T) {
+	checkRobots(t, "lowercase", "user-agent: FooBot\ndisallow: /x/\n",
+		robotsCase{"FooBot", "http://foo.bar/x/y", false},
+	)
+	checkRobots(t, "uppercase", "user-agent: FooBot\ndisallow: /X/\n",
+		robotsCase{"FooBot", "http://foo.bar/x/y", true},
+	)
+}
+
+func TestRFC9309LongestMatch(t *testing.T) {
+	checkRobots(t, "equal length, allow wins", "user-agent: FooBot\ndisallow: /x/page.html\nallow: /x/page.html\n",
+		robotsCase{"FooBot", "http://foo.bar/x/page.html", true},
+	)
+	checkRobots(t, "allow before disallow", "user-agent: FooBot\nallow: /x/page.html\ndisallow: /x/\n",
+		robotsCase{"FooBot", "http://foo.bar/x/page.html", true},
+		robotsCase{"FooBot", "http://foo.bar/x/", false},
+	)
+	checkRobots(t, "empty rules", "user-agent: FooBot\ndisallow: \nallow: \n",
+		robotsCase{"FooBot", "http://foo.bar/x/page.html", true},
+	)
+	checkRobots(t, "root", "user-agent: FooBot\ndisallow: /\nallow: /\n",
+		robotsCase{"FooBot", "http://foo.bar/", true},
+	)
+	checkRobots(t, "prefix", "user-agent: FooBot\ndisallow: /x\nallow: /x/\n",
+		robotsCase{"FooBot", "http://foo.bar/x", false},
+		robotsCase{"FooBot", "http://foo.bar/x/", true},
+	)
+	checkRobots(t, "wildcard length", "user-agent: FooBot\nallow: /page\ndisallow: /*.html\n",
+		robotsCase{"FooBot", "http://foo.bar/page.html", false},
+		robotsCase{"FooBot", "http://foo.bar/page", true},
+	)
+	checkRobots(t, "longer allow", "user-agent: FooBot\nallow: /x/page.\ndisallow: /*.html\n",
+		robotsCase{"FooBot", "http://foo.bar/x/page.html", true},
+		robotsCase{"FooBot", "http://foo.bar/x/y.html", false},
+	)
+	checkRobots(t, "specific group", "User-agent: *\nDisallow: /x/\nUser-agent: FooBot\nDisallow: /y/\n",
+		robotsCase{"FooBot", "http://foo.bar/x/page", true},
+		robotsCase{"FooBot", "http://foo.bar/y/page", false},
+	)
+}
+
+func TestRFC9309Encoding(t *testing.T) {
+	checkRobots(t, "query", "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar?qux=taz&baz=http://foo.bar?tar&par\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar?qux=taz&baz=http://foo.bar?tar&par", true},
+	)
+	checkRobots(t, "non-ascii rule", "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/ツ\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/%E3%83%84", true},
+	)
+	checkRobots(t, "encoded rule", "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/%E3%83%84\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/%E3%83%84", true},
+	)
+	checkRobots(t, "lowercase encoding", "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/%e3%83%84\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/%E3%83%84", true},
+	)
+	checkRobots(t, "unreserved characters are not decoded", "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/%62%61%7A\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/baz", false},
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/%62%61%7A", true},
+	)
+}
+
+func TestRFC9309SpecialCharacters(t *testing.T) {
+	checkRobots(t, "wildcard", "User-agent: FooBot\nDisallow: /foo/bar/quz\nAllow: /foo/*/qux\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/quz", false},
+		robotsCase{"FooBot", "http://foo.bar/foo/quz", true},
+		robotsCase{"FooBot", "http://foo.bar/foo//quz", true},
+		robotsCase{"FooBot", "http://foo.bar/foo/bax/quz", true},
+	)
+	checkRobots(t, "end of line", "User-agent: FooBot\nDisallow: /foo/bar$\nAllow: /foo/bar/qux\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar", false},
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/qux", true},
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/", true},
+		robotsCase{"FooBot", "http://foo.bar/foo/bar/baz", true},
+	)
+	checkRobots(t, "comments", "User-agent: FooBot\n# Disallow: /\nDisallow: /foo/quz#qux\nAllow: /\n",
+		robotsCase{"FooBot", "http://foo.bar/foo/bar", true},
+		robotsCase{"FooBot", "http://foo.bar/foo/quz", false},
+	)
+}
+
+func TestRFC9309RobotsTxtAlwaysAllowed(t *testing.T) {
+	checkRobots(t, "robots.txt", "user-agent: *\ndisallow: /\n",
+		robotsCase{"FooBot", "http://foo.bar/robots.txt", true},
+		robotsCase{"FooBot", "http://foo.bar/robots.txt.bak", false},
+	)
+}
+
+func TestRFC9309LineEndings(t *testing.T) {
+	for name, robotsTxt := range map[string]string{
+		"unix":    "User-Agent: foo\nAllow: /some/path\nUser-Agent: bar\n\n\nDisallow: /\n",
+		"windows": "User-Agent: foo\r\nAllow: /some/path\r\nUser-Agent: bar\r\n\r\n\r\nDisallow: /\r\n",
+		"bom":     "\ufeffUser-Agent: foo\nAllow: /some/path\nUser-Agent: bar\n\n\nDisallow: /\n",
+	} {
+		checkRobots(t, name, robotsTxt,
+			robotsCase{"foo", "http://foo.bar/some/path", true},
+			robotsCase{"bar", "http://foo.bar/", false},
+		)
+	}
+}
+
+func TestRFC9309Wildcards(t *testing.T) {
+	cases := []struct {
+		rule  string
+		url   string
+		match bool
+	}{
+		{"/a*b*c", "/abc", true},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axbxbxc/d", true},
+		{"/a*b*c", "/acb", false},
+		{"/a*b*c$", "/abcabd", false},
+		{"/a*b*c$", "/abcabc", true},
+		{"*", "", true},
+		{"/**/x", "/a/b/x", true},
+		{"/$", "/", true},
+		{"/$", "/a", false},
+	}
+	for _, c := range cases {
+		if robots.MatchURLRule(c.rule, c.url) != c.match {
+			t.Errorf("rule %s matching %s, expected %t", c.rule, c.url, c.match)
+		}
+	}
+}
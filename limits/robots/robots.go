@@ -7,62 +7,43 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-// RobotRules holds the robot exclusions for multiple domains.
-type RobotRules struct {
-	hosts map[string]*RobotFile
-	lock  sync.RWMutex
-}
-
-// NewRobotRules instantiates a new robot limit cache.
-func NewRobotRules() *RobotRules {
-	return &RobotRules{
-		hosts: make(map[string]*RobotFile),
-		lock:  sync.RWMutex{},
-	}
-}
+// MaxRobotFileSize is the maximum amount of bytes of a robots.txt file that are parsed, the rest is ignored.
+const MaxRobotFileSize = 500 * 1024
 
-// Allowed returns true if the userAgent is allowed to access the given path on the given domain.
-// Returns error if no robot file is cached for the given domain.
-func (c *RobotRules) Allowed(userAgent string, url *url.URL) (bool, error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// MaxRobotFileRedirects is the maximum amount of redirects followed when fetching a robots.txt file.
+const MaxRobotFileRedirects = 5
 
-	limits, ok := c.hosts[url.Host]
-	if !ok {
-		return false, fmt.Errorf("No limits found for domain %s", url.Host)
-	}
-	return limits.Allowed(userAgent, url.Path), nil
-}
-
-// GetRulesForHost gets the rules for a host. Returns an error when no limits are cached for the given host.
-func (c *RobotRules) GetRulesForHost(host string) (*RobotFile, error) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	limits, ok := c.hosts[host]
-	if !ok {
-		return nil, fmt.Errorf("No limits found for domain %s", host)
-	}
-	return limits, nil
-}
+// RobotFileStatus indicates the outcome of fetching a robots.txt file.
+type RobotFileStatus int
 
-// AddLimits adds or replaces the limits for a host.
-func (c *RobotRules) AddLimits(robotFile *RobotFile, host string) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	c.hosts[host] = robotFile
-}
+const (
+	// RobotFileFetched indicates the robots.txt file was downloaded and parsed.
+	RobotFileFetched RobotFileStatus = iota
+	// RobotFileUnavailable indicates a 4xx status code or too many redirects, all paths are allowed.
+	RobotFileUnavailable
+	// RobotFileUnreachable indicates a 5xx status code or network error, all paths are disallowed.
+	RobotFileUnreachable
+)
 
 // RobotFile holds all the information in a robots exclusion file.
+// Rules are interpreted according to RFC 9309.
 type RobotFile struct {
-	defaultLimits *UserAgentRules
-	groups        map[string]*UserAgentRules
-	sitemap       *url.URL
+	// groups holds the rules for each user agent product token, including the catch-all (*) group
+	groups   map[string]*UserAgentRules
+	sitemaps []*url.URL
+	// text holds the parsed part of the file, used to store the file in shared caches
+	text string
+
+	// fetch outcome
+	status     RobotFileStatus
+	statusCode int
+	fetchErr   error
+	expires    time.Time
 }
 
 func newRobotFile() *RobotFile {
@@ -71,126 +52,284 @@ func newRobotFile() *RobotFile {
 	}
 }
 
+// NewRobotFileFromURL downloads and parses a robots.txt file, following up to MaxRobotFileRedirects redirects.
+// Failing to fetch the file is not an error, the outcome is recorded on the RobotFile instead:
+// a 4xx status code allows all paths, a 5xx status code or network error disallows all paths, see Status.
+// Returns an error if the URL is invalid.
 func NewRobotFileFromURL(url *url.URL, client http.RoundTripper) (*RobotFile, error) {
-	request, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	res, err := client.RoundTrip(request)
-	if err != nil {
-		return nil, err
+	location := url
+	for redirects := 0; ; redirects++ {
+		request, err := http.NewRequest("GET", location.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.RoundTrip(request)
+		if err != nil {
+			return newUnreachableRobotFile(0, err), nil
+		}
+
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			defer res.Body.Close()
+			robotFile, err := NewRobotFileFromReader(io.LimitReader(res.Body, MaxRobotFileSize))
+			if err != nil {
+				// the body could not be read, handled like a network error
+				return newUnreachableRobotFile(res.StatusCode, err), nil
+			}
+			robotFile.statusCode = res.StatusCode
+			return robotFile, nil
+		}
+		res.Body.Close()
+
+		switch {
+		case res.StatusCode >= 500:
+			return newUnreachableRobotFile(res.StatusCode, nil), nil
+
+		case res.StatusCode >= 300 && res.StatusCode < 400 && res.Header.Get("Location") != "" && redirects < MaxRobotFileRedirects:
+			location, err = location.Parse(res.Header.Get("Location"))
+			if err != nil {
+				return newUnavailableRobotFile(res.StatusCode), nil
+			}
+
+		default:
+			// 4xx status codes, too many redirects or redirects without a location
+			return newUnavailableRobotFile(res.StatusCode), nil
+		}
 	}
-	defer res.Body.Close()
+}
+
+// newUnavailableRobotFile returns a RobotFile allowing all paths.
+func newUnavailableRobotFile(statusCode int) *RobotFile {
+	robotFile := newRobotFile()
+	robotFile.status = RobotFileUnavailable
+	robotFile.statusCode = statusCode
+	return robotFile
+}
 
-	return NewRobotFileFromReader(res.Body)
+// newUnreachableRobotFile returns a RobotFile disallowing all paths.
+func newUnreachableRobotFile(statusCode int, err error) *RobotFile {
+	robotFile := newRobotFile()
+	robotFile.status = RobotFileUnreachable
+	robotFile.statusCode = statusCode
+	robotFile.fetchErr = err
+	return robotFile
 }
 
-// RobotFileFromReader will parse a robot exclusion file from an io.Reader.
-// Returns a default error if it encounters an invalid directive.
+// NewRobotFileFromReader will parse a robot exclusion file from an io.Reader.
+// Lines that can't be parsed are ignored as required by RFC 9309, including invalid non-standard directive values, as are rules outside of a group.
+// Returns an error if the file could not be read or contains a line longer than MaxRobotFileSize.
 func NewRobotFileFromReader(in io.Reader) (*RobotFile, error) {
-	scanner := bufio.NewScanner(in)
+	text := strings.Builder{}
+	scanner := bufio.NewScanner(io.TeeReader(in, &text))
+	// lines may be as long as the file
+	scanner.Buffer(make([]byte, 0, 4096), MaxRobotFileSize+1)
 	limits := newRobotFile()
 
-	// current host specification
-	rules := newUserAgentRules("*")
-	for scanner.Scan() {
-		line := strings.Trim(scanner.Text(), " \t")
-
-		// ignore empty lines or comments
-		if len(line) < 1 || line[0] == '#' {
-			continue
+	// current group, nil until the first User-agent line
+	var rules *UserAgentRules
+	// true while reading the User-agent lines at the start of a group
+	readingUserAgents := false
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if lineNumber == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
 		}
 
-		// separate directive and parameter
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) < 1 {
-			return nil, fmt.Errorf("Invalid directive %s", line)
+		// strip comments
+		if i := strings.IndexByte(line, '#'); i > -1 {
+			line = line[:i]
 		}
-		directive := parts[0]
-		parameter := ""
-		if len(parts) > 1 {
-			// Trim parameter of leading spaces
-			parameter = strings.TrimPrefix(parts[1], " ")
+
+		directive, parameter, ok := splitDirective(line)
+		if !ok {
+			continue
 		}
 
-		// Match directive
-		switch strings.ToLower(directive) {
+		// Match directives that are not part of a group
+		switch directive {
 		case "user-agent":
-			if parameter == "" {
-				return nil, fmt.Errorf("Invalid User-agent directive %s", line)
+			userAgent := productToken(parameter)
+			if userAgent == "" {
+				continue
+			}
+			// A User-agent line following group rules starts a new group, consecutive User-agent lines share a group.
+			if !readingUserAgents {
+				limits.addUserAgentRules(rules)
+				rules = newUserAgentRules(userAgent)
+				readingUserAgents = true
+				continue
 			}
-			// Add the current user-agent rules to the RobotFile and begin a new UserAgentRules.
-			limits.addUserAgentRules(rules)
-			rules = newUserAgentRules(parameter)
+			rules.userAgents = append(rules.userAgents, userAgent)
+			continue
 
+		case "sitemap":
+			url, err := url.Parse(parameter)
+			if err != nil {
+				continue
+			}
+			limits.sitemaps = append(limits.sitemaps, url)
+			continue
+		}
+
+		// Group rules are ignored before the first User-agent line
+		if rules == nil {
+			continue
+		}
+
+		// Match group directives
+		switch directive {
 		case "disallow":
-			if parameter == "" {
-				// Reset the disallowed on empty string
-				rules.disallowed = make([]string, 0)
-			} else {
-				rules.disallowed = append(rules.disallowed, parameter)
+			// An empty rule matches nothing
+			if parameter != "" {
+				rules.disallowed = append(rules.disallowed, normalizeEncoding(parameter))
 			}
 
 		case "allow":
-			if parameter == "" {
-				// Reset the allowed on empty string
-				rules.allowed = make([]string, 0)
-			} else {
-				rules.allowed = append(rules.allowed, parameter)
+			if parameter != "" {
+				rules.allowed = append(rules.allowed, normalizeEncoding(parameter))
 			}
 
 		case "crawl-delay":
 			dur, err := time.ParseDuration(fmt.Sprintf("%ss", parameter))
-			if err != nil {
-				return nil, err
-			}
-			if dur.Seconds() < 0 {
-				return nil, fmt.Errorf("Negative crawl-delay not allowed")
+			if err != nil || dur < 0 {
+				continue
 			}
 			rules.delay = dur
 
-		case "sitemap":
-			url, err := url.Parse(parameter)
+		case "request-rate":
+			rate, err := parseRequestRate(parameter)
+			if err != nil {
+				continue
+			}
+			rules.requestRates = append(rules.requestRates, rate)
+
+		case "visit-time":
+			visitTime, err := parseVisitTime(parameter)
 			if err != nil {
-				return nil, err
+				continue
 			}
-			limits.sitemap = url
+			rules.visitTimes = append(rules.visitTimes, visitTime)
 
 		default:
 			// Unknown directive, ignore
 			continue
 		}
+		readingUserAgents = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 	limits.addUserAgentRules(rules)
+	limits.text = text.String()
 	return limits, nil
 }
 
+// splitDirective splits a line into a lowercase directive and its parameter, trimming any whitespace.
+// The separating colon may be omitted if the directive and parameter are separated by whitespace.
+// Returns false if the line is empty or does not contain a directive.
+func splitDirective(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", "", false
+	}
+
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		i = strings.IndexAny(line, " \t")
+		if i < 0 {
+			return "", "", false
+		}
+	}
+	directive := strings.ToLower(strings.TrimSpace(line[:i]))
+	if directive == "" {
+		return "", "", false
+	}
+	return directive, strings.TrimSpace(line[i+1:]), true
+}
+
+// productToken returns the lowercase product token of a user agent, e.a. "Googlebot/2.1 (+http://www.google.com/bot.html)" becomes "googlebot".
+// Returns "*" for the catch-all user agent.
+func productToken(userAgent string) string {
+	if strings.HasPrefix(userAgent, "*") {
+		return "*"
+	}
+	for i := 0; i < len(userAgent); i++ {
+		c := userAgent[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_') {
+			return strings.ToLower(userAgent[:i])
+		}
+	}
+	return strings.ToLower(userAgent)
+}
+
+// addUserAgentRules merges the group into the rules of each of its user agents.
 func (l *RobotFile) addUserAgentRules(g *UserAgentRules) {
-	if g.userAgent == "*" {
-		l.defaultLimits = g
+	if g == nil {
 		return
 	}
-	l.groups[g.userAgent] = g
+	for _, userAgent := range g.userAgents {
+		rules, ok := l.groups[userAgent]
+		if !ok {
+			rules = newUserAgentRules(userAgent)
+			l.groups[userAgent] = rules
+		}
+		rules.merge(g)
+	}
 }
 
-// Allowed returns true if the user agent is allowed to access the given url.
-func (l *RobotFile) Allowed(userAgent, url string) bool {
-	group, ok := l.groups[userAgent]
-	if ok {
-		return group.Allowed(url)
+// Allowed returns true if the user agent is allowed to access the given path.
+// The path should be percent-encoded and include the query, see url.URL.RequestURI.
+// The robots.txt file itself is always allowed, all other paths are disallowed if the file was unreachable.
+func (l *RobotFile) Allowed(userAgent, path string) bool {
+	if path == "/robots.txt" {
+		return true
 	}
-	return l.defaultLimits.Allowed(url)
+	if l.status == RobotFileUnreachable {
+		return false
+	}
+	return l.GetUserAgentRules(userAgent).Allowed(path)
+}
+
+// Status returns the outcome of fetching the file.
+func (l *RobotFile) Status() RobotFileStatus {
+	return l.status
+}
+
+// StatusCode returns the HTTP status code of the final response when fetching the file, 0 if no response was received.
+func (l *RobotFile) StatusCode() int {
+	return l.statusCode
+}
+
+// FetchError returns the error encountered when fetching the file, nil if a response was received.
+func (l *RobotFile) FetchError() error {
+	return l.fetchErr
+}
+
+// Expires returns the time after which the file should be fetched again, the zero time if it does not expire.
+func (l *RobotFile) Expires() time.Time {
+	return l.expires
+}
+
+// SetExpires sets the time after which the file should be fetched again.
+func (l *RobotFile) SetExpires(expires time.Time) {
+	l.expires = expires
+}
+
+// Expired returns true if the file has expired at the given time.
+func (l *RobotFile) Expired(now time.Time) bool {
+	return !l.expires.IsZero() && now.After(l.expires)
 }
 
 // GetUserAgentRules gets the rules for the userAgent, returns the default (*) group if it was present and no other groups apply.
-// Returns nil if no groups apply and no default group was supplied.
+// User agents are matched case-insensitively on their product token, e.a. "Googlebot/2.1" matches the "googlebot" group.
+// Returns empty rules, allowing everything, if no groups apply and no default group was supplied.
 func (l *RobotFile) GetUserAgentRules(userAgent string) *UserAgentRules {
-	for _, group := range l.groups {
-		if group.userAgent == userAgent {
-			return group
-		}
+	if group, ok := l.groups[productToken(userAgent)]; ok {
+		return group
+	}
+	if group, ok := l.groups["*"]; ok {
+		return group
 	}
-	return l.defaultLimits
+	return newUserAgentRules("*")
 }
 
 // GetDelay returns the User-agent specific crawl-delay if it exists, otherwise the catch-all delay.
@@ -199,52 +338,101 @@ func (l *RobotFile) GetDelay(userAgent string, defaultDelay time.Duration) time.
 	return l.GetUserAgentRules(userAgent).GetDelay(defaultDelay)
 }
 
-// Sitemap returns the URL to the sitemap for the given User-agent.
-// Returns the default sitemap if no User-agent specific sitemap was specified, otherwise nil.
-func (l *RobotFile) GetSitemap(userAgent string, client http.RoundTripper) (*Sitemap, error) {
-	if l.sitemap == nil {
+// GetRequestInterval returns the time between requests imposed by the User-agent specific Request-rate if it exists, otherwise the catch-all Request-rate.
+// Returns defaultInterval if neither a specific or global Request-rate applies at the given time.
+func (l *RobotFile) GetRequestInterval(userAgent string, now time.Time, defaultInterval time.Duration) time.Duration {
+	return l.GetUserAgentRules(userAgent).GetRequestInterval(now, defaultInterval)
+}
+
+// GetVisitTimes returns the User-agent specific visit times if they exist, otherwise the catch-all visit times.
+func (l *RobotFile) GetVisitTimes(userAgent string) []VisitTime {
+	return l.GetUserAgentRules(userAgent).GetVisitTimes()
+}
+
+// Sitemaps returns the URLs of all sitemaps listed in the file, in the order they were listed.
+// Sitemaps apply to all user agents.
+func (l *RobotFile) Sitemaps() []*url.URL {
+	return l.sitemaps
+}
+
+// GetSitemaps downloads and parses all sitemaps listed in the file.
+// Returns an error if the file lists no sitemaps or any of them could not be downloaded.
+func (l *RobotFile) GetSitemaps(client http.RoundTripper) ([]*Sitemap, error) {
+	if len(l.sitemaps) < 1 {
 		return nil, errors.New("No sitemap in robots.txt")
 	}
 
-	return NewSitemapFromURL(l.sitemap.String(), client)
+	sitemaps := make([]*Sitemap, 0, len(l.sitemaps))
+	for _, sitemapURL := range l.sitemaps {
+		sitemap, err := NewSitemapFromURL(sitemapURL.String(), client)
+		if err != nil {
+			return nil, err
+		}
+		sitemaps = append(sitemaps, sitemap)
+	}
+	return sitemaps, nil
 }
 
-// UserAgentRules holds limits for a single user agent.
+// UserAgentRules holds limits for a group of user agents.
 type UserAgentRules struct {
-	userAgent  string
-	allowed    []string
-	disallowed []string
-	delay      time.Duration
+	userAgents   []string
+	allowed      []string
+	disallowed   []string
+	delay        time.Duration
+	requestRates []RequestRate
+	visitTimes   []VisitTime
 }
 
 func newUserAgentRules(userAgent string) *UserAgentRules {
 	return &UserAgentRules{
-		userAgent:  userAgent,
+		userAgents: []string{userAgent},
 		allowed:    make([]string, 0),
 		disallowed: make([]string, 0),
 		delay:      -1,
 	}
 }
 
+// merge adds the rules of another group.
+func (g *UserAgentRules) merge(other *UserAgentRules) {
+	g.allowed = append(g.allowed, other.allowed...)
+	g.disallowed = append(g.disallowed, other.disallowed...)
+	if other.delay > -1 {
+		g.delay = other.delay
+	}
+	g.requestRates = append(g.requestRates, other.requestRates...)
+	g.visitTimes = append(g.visitTimes, other.visitTimes...)
+}
+
 // Applies returns true if the group applies to the given userAgent
 func (g *UserAgentRules) Applies(userAgent string) bool {
-	return g.userAgent == userAgent
+	token := productToken(userAgent)
+	for _, groupAgent := range g.userAgents {
+		if groupAgent == token {
+			return true
+		}
+	}
+	return false
 }
 
 // Allowed returns true if the url is allowed by the group rules. Check if the group applies to the user agent first by using Applies.
+// The longest matching rule takes precedence, allow rules take precedence over disallow rules of the same length.
 func (g *UserAgentRules) Allowed(url string) bool {
-	for _, rule := range g.allowed {
-		if MatchURLRule(rule, url) {
-			return true
-		}
+	url = normalizeEncoding(url)
+	if url == "" {
+		url = "/"
 	}
-	for _, rule := range g.disallowed {
-		if MatchURLRule(rule, url) {
-			return false
+	return longestMatch(g.allowed, url) >= longestMatch(g.disallowed, url)
+}
 
+// longestMatch returns the length of the longest rule matching the url, -1 if no rules match.
+func longestMatch(rules []string, url string) int {
+	longest := -1
+	for _, rule := range rules {
+		if len(rule) > longest && MatchURLRule(rule, url) {
+			longest = len(rule)
 		}
 	}
-	return true
+	return longest
 }
 
 // GetDelay returns the Crawl-delay.
@@ -256,55 +444,205 @@ func (g *UserAgentRules) GetDelay(defaultDelay time.Duration) time.Duration {
 	return g.delay
 }
 
-func matchWildcard(seekChar byte, value string) (int, bool) {
+// GetRequestRates returns the Request-rates, nil if none were specified.
+func (g *UserAgentRules) GetRequestRates() []RequestRate {
+	return g.requestRates
+}
+
+// GetRequestInterval returns the time between requests imposed by the Request-rates applying at the given time.
+// If multiple rates apply, the longest interval is returned.
+// Returns defaultInterval if no Request-rate applies.
+func (g *UserAgentRules) GetRequestInterval(now time.Time, defaultInterval time.Duration) time.Duration {
+	interval := defaultInterval
+	applies := false
+	for _, rate := range g.requestRates {
+		if rate.VisitTime != nil && !rate.VisitTime.Contains(now) {
+			continue
+		}
+		if !applies || rate.Interval() > interval {
+			interval = rate.Interval()
+			applies = true
+		}
+	}
+	return interval
+}
+
+// GetVisitTimes returns the Visit-time windows, nil if none were specified.
+func (g *UserAgentRules) GetVisitTimes() []VisitTime {
+	return g.visitTimes
+}
+
+// RequestRate is the maximum rate at which a site may be crawled, specified by the non-standard Request-rate directive.
+type RequestRate struct {
+	Requests int
+	Period   time.Duration
+	// VisitTime limits the rate to a window of the day, nil if the rate always applies.
+	VisitTime *VisitTime
+}
+
+// Interval returns the minimum time between requests.
+func (r RequestRate) Interval() time.Duration {
+	return r.Period / time.Duration(r.Requests)
+}
+
+// parseRequestRate parses a Request-rate value in the <requests>/<period>[unit] format, optionally followed by a HHMM-HHMM visit time.
+// Units are s (default), m and h.
+func parseRequestRate(value string) (RequestRate, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 1 || len(fields) > 2 {
+		return RequestRate{}, fmt.Errorf("Invalid Request-rate %s", value)
+	}
+
+	parts := strings.SplitN(fields[0], "/", 2)
+	if len(parts) < 2 {
+		return RequestRate{}, fmt.Errorf("Invalid Request-rate %s", value)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 1 {
+		return RequestRate{}, fmt.Errorf("Invalid Request-rate %s", value)
+	}
+
+	period := parts[1]
+	if period == "" {
+		return RequestRate{}, fmt.Errorf("Invalid Request-rate %s", value)
+	}
+	unit := time.Second
+	switch period[len(period)-1] {
+	case 's':
+		period = period[:len(period)-1]
+	case 'm':
+		period, unit = period[:len(period)-1], time.Minute
+	case 'h':
+		period, unit = period[:len(period)-1], time.Hour
+	}
+	amount, err := strconv.Atoi(period)
+	if err != nil || amount < 1 {
+		return RequestRate{}, fmt.Errorf("Invalid Request-rate %s", value)
+	}
+
+	rate := RequestRate{
+		Requests: requests,
+		Period:   time.Duration(amount) * unit,
+	}
+	if len(fields) > 1 {
+		visitTime, err := parseVisitTime(fields[1])
+		if err != nil {
+			return RequestRate{}, err
+		}
+		rate.VisitTime = &visitTime
+	}
+	return rate, nil
+}
+
+// VisitTime is a window during which the site may be crawled, specified by the non-standard Visit-time directive.
+// Start and End are offsets from midnight UTC, a window with an End before its Start spans midnight.
+type VisitTime struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains returns true if the time falls within the visit time.
+func (v VisitTime) Contains(t time.Time) bool {
+	t = t.UTC()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if v.End > v.Start {
+		return offset >= v.Start && offset < v.End
+	}
+	// the window spans midnight
+	return offset >= v.Start || offset < v.End
+}
+
+// parseVisitTime parses a Visit-time value in the HHMM-HHMM format.
+func parseVisitTime(value string) (VisitTime, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "-", 2)
+	if len(parts) < 2 {
+		return VisitTime{}, fmt.Errorf("Invalid Visit-time %s", value)
+	}
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return VisitTime{}, err
+	}
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return VisitTime{}, err
+	}
+	return VisitTime{start, end}, nil
+}
+
+// parseTimeOfDay parses a time of day in the HHMM format, returning the offset from midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if len(value) != 4 || strings.Trim(value, "0123456789") != "" {
+		return 0, fmt.Errorf("Invalid time of day %s", value)
+	}
+	hours := int(value[0]-'0')*10 + int(value[1]-'0')
+	minutes := int(value[2]-'0')*10 + int(value[3]-'0')
+	if hours > 24 || minutes > 59 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("Invalid time of day %s", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// normalizeEncoding percent-encodes octets outside of printable ASCII and uppercases existing percent-encodings,
+// allowing rules and paths to be compared octet by octet.
+func normalizeEncoding(value string) string {
+	var builder strings.Builder
 	for i := 0; i < len(value); i++ {
-		if value[i] == seekChar {
-			return i, true
+		c := value[i]
+		switch {
+		case c == '%' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]):
+			builder.WriteByte('%')
+			builder.WriteString(strings.ToUpper(value[i+1 : i+3]))
+			i += 2
+		case c <= ' ' || c >= 0x7f:
+			fmt.Fprintf(&builder, "%%%02X", c)
+		default:
+			builder.WriteByte(c)
 		}
 	}
-	// return false if seekChar was not ofund
-	return 0, false
+	return builder.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
 }
 
-// MatchURLRule will return true if the given robot exclusion rule matches the given URL.
-// Supports wildcards ('*') and end of line ('$').
+// MatchURLRule will return true if the given robot exclusion rule matches the start of the given URL.
+// Supports wildcards ('*'), matching any sequence of characters, and end of line ('$') as the last character of the rule.
 func MatchURLRule(rule, url string) bool {
-	// if the rule is longer than the url, return false
-	if len(rule) > len(url) {
-		return false
+	if !strings.ContainsAny(rule, "*$") {
+		return strings.HasPrefix(url, rule)
 	}
 
-	// j is the current character in url
-	j := 0
+	// positions holds the indexes in url up to which the rule has matched so far, in ascending order
+	positions := make([]int, 1, len(url)+1)
 	for i := 0; i < len(rule); i++ {
-		switch rule[i] {
-		// wildcard: loop until next rule characer is found
-		case '*':
-			// return true if last rule character is *
-			if i+1 == len(rule) {
-				return true
-			}
-
-			// loop until next rule character is found in url, return false if not found
-			seekChar := rule[i+1]
-			add, match := matchWildcard(seekChar, url[j:])
-			j += add
-			if !match {
-				return false
+		switch {
+		// end of line, return true if any match reached the end of url
+		case rule[i] == '$' && i+1 == len(rule):
+			return positions[len(positions)-1] == len(url)
+
+		// wildcard: the rule matches up to every index from the first match onwards
+		case rule[i] == '*':
+			first := positions[0]
+			positions = positions[:0]
+			for j := first; j <= len(url); j++ {
+				positions = append(positions, j)
 			}
 
-		// end of line, return true if we have actually reached the end of url
-		case '$':
-			return j == len(url)
-
-		// check if url and rule matches on indexes j, i
+		// keep the matches for which the next url character matches the rule
 		default:
-			if j >= len(url) || rule[i] != url[j] {
+			matches := positions[:0]
+			for _, j := range positions {
+				if j < len(url) && url[j] == rule[i] {
+					matches = append(matches, j+1)
+				}
+			}
+			positions = matches
+			if len(positions) == 0 {
 				return false
 			}
-			j++
 		}
-
 	}
 	return true
 }
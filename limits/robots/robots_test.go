@@ -1,8 +1,15 @@
 package robots_test
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/KillianMeersman/wander/limits/robots"
 )
@@ -47,6 +54,79 @@ func TestRobotLimits(t *testing.T) {
 	}
 }
 
+func TestVisitTime(t *testing.T) {
+	reader := strings.NewReader(`
+User-agent: *
+Disallow: /private
+Visit-time: 2200-0600
+Visit-time: 1200-1300
+`)
+	limits, err := robots.NewRobotFileFromReader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	visitTimes := limits.GetVisitTimes("wander")
+	if len(visitTimes) != 2 {
+		t.Fatalf("expected 2 visit times, got %d", len(visitTimes))
+	}
+	if visitTimes[0].Start != 22*time.Hour || visitTimes[0].End != 6*time.Hour {
+		t.Fatalf("invalid visit time %v", visitTimes[0])
+	}
+
+	limits, err = robots.NewRobotFileFromReader(strings.NewReader("User-agent: *\nVisit-time: 2500-0100\nDisallow: /private\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limits.GetVisitTimes("wander")) != 0 {
+		t.Fatal("invalid visit time should be ignored")
+	}
+	if limits.Allowed("wander", "/private") {
+		t.Fatal("rules following an invalid visit time should be applied")
+	}
+}
+
+func TestRequestRate(t *testing.T) {
+	reader := strings.NewReader(`
+User-agent: *
+Crawl-delay: 2
+Request-rate: 1/10s
+Request-rate: 1/1m 0800-1700
+
+User-agent: fastbot
+Request-rate: 30/1m
+`)
+	limits, err := robots.NewRobotFileFromReader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	night := time.Date(2020, time.June, 20, 23, 0, 0, 0, time.UTC)
+	if interval := limits.GetRequestInterval("wander", night, -1); interval != 10*time.Second {
+		t.Fatalf("expected interval of 10s, got %s", interval)
+	}
+	day := time.Date(2020, time.June, 20, 12, 0, 0, 0, time.UTC)
+	if interval := limits.GetRequestInterval("wander", day, -1); interval != time.Minute {
+		t.Fatalf("expected interval of 1m, got %s", interval)
+	}
+	if interval := limits.GetRequestInterval("fastbot", day, -1); interval != 2*time.Second {
+		t.Fatalf("expected interval of 2s, got %s", interval)
+	}
+
+	for _, rate := range []string{"1", "0/10s", "1/0s", "a/10s", "1/10x", "1/10s 0800", "1/", "1/s"} {
+		limits, err = robots.NewRobotFileFromReader(strings.NewReader("User-agent: *\nRequest-rate: " + rate + "\nCrawl-delay: 3\n"))
+		if err != nil {
+			t.Fatalf("invalid request rate %s should be ignored, got %v", rate, err)
+		}
+		if len(limits.GetUserAgentRules("wander").GetRequestRates()) != 0 {
+			t.Fatalf("invalid request rate %s should be ignored", rate)
+		}
+		if delay := limits.GetDelay("wander", 0); delay != 3*time.Second {
+			t.Fatalf("rules following invalid request rate %s should be applied, got delay %s", rate, delay)
+		}
+	}
+}
+
 func TestMatchURL(t *testing.T) {
 	if !robots.MatchURLRule("/*/*/test", "/hello/world/test") {
 		t.FailNow()
@@ -91,3 +171,119 @@ func TestMatchURL(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestRobotFileFetchStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/missing/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>Not found</html>", http.StatusNotFound)
+	})
+	mux.HandleFunc("/error/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Disallow: /", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if hops > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/redirect/%d", hops-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /\n"))
+	})
+	mux.HandleFunc("/large/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\n"))
+		w.Write(bytes.Repeat([]byte("# padding\n"), robots.MaxRobotFileSize/10))
+		w.Write([]byte("Disallow: /\n"))
+	})
+	mux.HandleFunc("/invalid/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nCrawl-delay: soon\nRequest-rate: 1/\nDisallow: /\n"))
+	})
+	mux.HandleFunc("/long/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\n# "))
+		w.Write(bytes.Repeat([]byte("x"), 100*1024))
+		w.Write([]byte("\nDisallow: /\n"))
+	})
+	mux.HandleFunc("/truncated/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("User-agent: *\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetch := func(path string) *robots.RobotFile {
+		u, err := url.Parse(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		robotFile, err := robots.NewRobotFileFromURL(u, http.DefaultTransport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return robotFile
+	}
+
+	cases := []struct {
+		path    string
+		status  robots.RobotFileStatus
+		allowed bool
+	}{
+		{"/ok/robots.txt", robots.RobotFileFetched, true},
+		{"/missing/robots.txt", robots.RobotFileUnavailable, true},
+		{"/error/robots.txt", robots.RobotFileUnreachable, false},
+		{"/redirect/5", robots.RobotFileFetched, false},
+		{"/redirect/6", robots.RobotFileUnavailable, true},
+		{"/large/robots.txt", robots.RobotFileFetched, true},
+		{"/invalid/robots.txt", robots.RobotFileFetched, false},
+		{"/long/robots.txt", robots.RobotFileFetched, false},
+		{"/truncated/robots.txt", robots.RobotFileUnreachable, false},
+	}
+	for _, c := range cases {
+		robotFile := fetch(c.path)
+		if robotFile.Status() != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.path, c.status, robotFile.Status())
+		}
+		if robotFile.Allowed("wander", "/page") != c.allowed {
+			t.Errorf("%s: expected allowed %t", c.path, c.allowed)
+		}
+	}
+
+	// network errors disallow all paths
+	u, _ := url.Parse("http://127.0.0.1:1/robots.txt")
+	robotFile, err := robots.NewRobotFileFromURL(u, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if robotFile.Status() != robots.RobotFileUnreachable || robotFile.FetchError() == nil {
+		t.Fatal("unreachable robots.txt should record the error")
+	}
+	if robotFile.Allowed("wander", "/") || !robotFile.Allowed("wander", "/robots.txt") {
+		t.Fatal("unreachable robots.txt should disallow all paths except itself")
+	}
+}
+
+func TestInvalidLinesIgnored(t *testing.T) {
+	reader := strings.NewReader(`
+User-agent: *
+Crawl-delay: soon
+Crawl-delay: -1
+Disallow: /private
+Request-rate: 1/
+Visit-time: noon
+Sitemap: http://[::1
+Allow: /private/public
+`)
+	limits, err := robots.NewRobotFileFromReader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay := limits.GetDelay("wander", time.Second); delay != time.Second {
+		t.Fatalf("invalid crawl delays should be ignored, got %s", delay)
+	}
+	if limits.Allowed("wander", "/private") || !limits.Allowed("wander", "/private/public") {
+		t.Fatal("rules around invalid lines should be applied")
+	}
+	if len(limits.Sitemaps()) != 0 {
+		t.Fatal("invalid sitemap url should be ignored")
+	}
+}
@@ -0,0 +1,109 @@
+package robots
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultRobotRulesTTL is the default time robots.txt files are cached for.
+const DefaultRobotRulesTTL = 24 * time.Hour
+
+// RobotRules caches the robot exclusions for multiple hosts.
+// Cached files expire after a TTL or when their own expiry time passes, whichever comes first.
+type RobotRules interface {
+	// GetRulesForHost gets the rules for a host. Returns an error when no limits are cached for the given host or they have expired.
+	GetRulesForHost(host string) (*RobotFile, error)
+	// AddLimits adds or replaces the limits for a host.
+	AddLimits(robotFile *RobotFile, host string) error
+	// Allowed returns true if the userAgent is allowed to access the given url.
+	// Returns error if no unexpired robot file is cached for the url's host.
+	Allowed(userAgent string, url *url.URL) (bool, error)
+	// Clear removes all cached robot files.
+	Clear() error
+}
+
+// robotRulesEntry is a cached robot file and the time it expires.
+type robotRulesEntry struct {
+	robotFile *RobotFile
+	expires   time.Time
+}
+
+// LocalRobotRules holds the robot exclusions for multiple hosts in memory. Safe for use by multiple goroutines.
+type LocalRobotRules struct {
+	hosts map[string]robotRulesEntry
+	ttl   time.Duration
+	lock  sync.RWMutex
+}
+
+// NewRobotRules instantiates a new in-memory robot limit cache, caching files for DefaultRobotRulesTTL.
+func NewRobotRules() *LocalRobotRules {
+	return NewRobotRulesWithTTL(DefaultRobotRulesTTL)
+}
+
+// NewRobotRulesWithTTL instantiates a new in-memory robot limit cache, caching files for the given duration.
+func NewRobotRulesWithTTL(ttl time.Duration) *LocalRobotRules {
+	return &LocalRobotRules{
+		hosts: make(map[string]robotRulesEntry),
+		ttl:   ttl,
+		lock:  sync.RWMutex{},
+	}
+}
+
+// Allowed returns true if the userAgent is allowed to access the given path on the given domain.
+// Returns error if no unexpired robot file is cached for the given domain.
+func (c *LocalRobotRules) Allowed(userAgent string, url *url.URL) (bool, error) {
+	limits, err := c.GetRulesForHost(url.Host)
+	if err != nil {
+		return false, err
+	}
+	return limits.Allowed(userAgent, url.RequestURI()), nil
+}
+
+// GetRulesForHost gets the rules for a host. Returns an error when no limits are cached for the given host or they have expired.
+func (c *LocalRobotRules) GetRulesForHost(host string) (*RobotFile, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	entry, ok := c.hosts[host]
+	if !ok || !time.Now().Before(entry.expires) {
+		return nil, fmt.Errorf("No limits found for domain %s", host)
+	}
+	return entry.robotFile, nil
+}
+
+// AddLimits adds or replaces the limits for a host.
+func (c *LocalRobotRules) AddLimits(robotFile *RobotFile, host string) error {
+	c.add(robotFile, host, expiryTime(robotFile, time.Now(), c.ttl))
+	return nil
+}
+
+// add adds or replaces the limits for a host, expiring at the given time.
+func (c *LocalRobotRules) add(robotFile *RobotFile, host string, expires time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.hosts[host] = robotRulesEntry{
+		robotFile: robotFile,
+		expires:   expires,
+	}
+}
+
+// Clear removes all cached robot files.
+func (c *LocalRobotRules) Clear() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.hosts = make(map[string]robotRulesEntry)
+	return nil
+}
+
+// expiryTime returns the time a robot file added at the given time expires.
+func expiryTime(robotFile *RobotFile, now time.Time, ttl time.Duration) time.Time {
+	expires := now.Add(ttl)
+	if fileExpires := robotFile.Expires(); !fileExpires.IsZero() && fileExpires.Before(expires) {
+		return fileExpires
+	}
+	return expires
+}
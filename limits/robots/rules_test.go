@@ -0,0 +1,127 @@
+package robots_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+)
+
+func testRobotRules(t *testing.T, rules robots.RobotRules) {
+	robotFile, err := robots.NewRobotFileFromReader(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rules.AddLimits(robotFile, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := rules.GetRulesForHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.Allowed("Baidu", "/") || !cached.Allowed("Slurp", "/test/1") {
+		t.Fatal("cached rules differ from the original")
+	}
+	allowed, err := rules.Allowed("Baidu", &url.URL{Scheme: "http", Host: "example.com", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed {
+		t.Fatal("Baidu should not be allowed")
+	}
+	if _, err := rules.GetRulesForHost("example.org"); err == nil {
+		t.Fatal("rules returned for uncached host")
+	}
+
+	err = rules.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rules.GetRulesForHost("example.com"); err == nil {
+		t.Fatal("rules returned after clearing")
+	}
+}
+
+func TestLocalRobotRules(t *testing.T) {
+	testRobotRules(t, robots.NewRobotRules())
+}
+
+func TestRobotRulesExpiry(t *testing.T) {
+	rules := robots.NewRobotRulesWithTTL(100 * time.Millisecond)
+	robotFile, err := robots.NewRobotFileFromReader(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.AddLimits(robotFile, "example.com")
+
+	// the file's own expiry takes precedence when it is earlier than the TTL
+	shortLived, err := robots.NewRobotFileFromReader(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortLived.SetExpires(time.Now().Add(10 * time.Millisecond))
+	rules.AddLimits(shortLived, "example.org")
+
+	<-time.After(50 * time.Millisecond)
+	if _, err := rules.GetRulesForHost("example.com"); err != nil {
+		t.Fatal("rules expired before their TTL")
+	}
+	if _, err := rules.GetRulesForHost("example.org"); err == nil {
+		t.Fatal("rules not expired after the file's expiry time")
+	}
+
+	<-time.After(100 * time.Millisecond)
+	if _, err := rules.GetRulesForHost("example.com"); err == nil {
+		t.Fatal("rules not expired after their TTL")
+	}
+}
+
+func TestRedisRobotRules(t *testing.T) {
+	rules, err := robots.NewRedisRobotRules("localhost", 6379, "", "wander_robot_rules", 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRobotRules(t, rules)
+}
+
+func TestRedisRobotRulesLocalCache(t *testing.T) {
+	rules, err := robots.NewRedisRobotRules("localhost", 6379, "", "wander_robot_rules_cache", 1, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rules.Clear()
+	robotFile, err := robots.NewRobotFileFromReader(strings.NewReader(robotsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rules.AddLimits(robotFile, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another process sharing the rules parses the file once
+	other, err := robots.NewRedisRobotRules("localhost", 6379, "", "wander_robot_rules_cache", 1, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := other.GetRulesForHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := other.GetRulesForHost("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("rules parsed again while cached locally")
+	}
+
+	<-time.After(300 * time.Millisecond)
+	if expired, err := other.GetRulesForHost("example.com"); err == nil && expired == first {
+		t.Fatal("local rules not expired with the stored TTL")
+	}
+}
@@ -1,20 +1,31 @@
 package robots
 
 import (
-	"encoding/xml"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
+// SitemapLocation is a page or sitemap listed in a sitemap.
 type SitemapLocation struct {
 	Loc        string    `xml:"loc"`
 	LastMod    time.Time `xml:"lastmod"`
 	ChangeFreq string    `xml:"changefreq"`
 	Priority   float64   `xml:"priority"`
+
+	// Extensions, only set for page locations
+	Images     []SitemapImage
+	Videos     []SitemapVideo
+	News       *SitemapNews
+	Alternates []SitemapAlternate
 }
 
+// Sitemap holds the locations listed in a sitemap.
 type Sitemap struct {
 	Index  []SitemapLocation `xml:"sitemap"`
 	URLSet []SitemapLocation `xml:"url"`
@@ -27,18 +38,29 @@ func NewSitemap() *Sitemap {
 	}
 }
 
-func NewSitemapFromReader(reader io.Reader) (*Sitemap, error) {
-	var sitemap Sitemap
-	data, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, err
-	}
-	err = xml.Unmarshal(data, &sitemap)
+// NewSitemapFromReader reads all locations in a sitemap into memory.
+// Supports the same formats as SitemapReader, use a SitemapReader directly to process large sitemaps.
+func NewSitemapFromReader(in io.Reader) (*Sitemap, error) {
+	reader, err := NewSitemapReader(in)
 	if err != nil {
 		return nil, err
 	}
 
-	return &sitemap, nil
+	sitemap := NewSitemap()
+	for {
+		location, err := reader.Next()
+		if err == io.EOF {
+			return sitemap, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if reader.IsIndex() {
+			sitemap.Index = append(sitemap.Index, location)
+		} else {
+			sitemap.URLSet = append(sitemap.URLSet, location)
+		}
+	}
 }
 
 func NewSitemapFromURL(url string, client http.RoundTripper) (*Sitemap, error) {
@@ -47,41 +69,163 @@ func NewSitemapFromURL(url string, client http.RoundTripper) (*Sitemap, error) {
 		return nil, err
 	}
 	res, err := client.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Sitemap request returned status code %d", res.StatusCode)
+	}
 	return NewSitemapFromReader(res.Body)
 }
 
-// GetLocations gets up to <limit> sitemap locations.
-// Sitemaps usually come in pages of 50k entries, this means the limit may be exceeded by up to 49_999 entries.
-func (s *Sitemap) GetLocations(client http.RoundTripper, limit int) ([]SitemapLocation, error) {
-	urls := s.URLSet
+// sitemapProbePaths are the conventional sitemap locations probed by DiscoverSitemaps.
+var sitemapProbePaths = []string{"/sitemap.xml", "/sitemap_index.xml"}
 
-	for _, index := range s.Index {
-		if len(urls) >= limit {
-			break
-		}
+// DiscoverSitemaps finds the sitemaps of a host.
+// Returns the sitemaps listed in the host's robots.txt file if there are any,
+// otherwise the conventional /sitemap.xml and /sitemap_index.xml locations are probed
+// and the host's home page is searched for <link rel="sitemap"> elements.
+// Only the first maxPageSize bytes of the home page are searched, the page is not limited if maxPageSize is 0 or less.
+// Returns an empty slice if no sitemaps were found.
+func DiscoverSitemaps(host *url.URL, client http.RoundTripper, maxPageSize int64) ([]*url.URL, error) {
+	root := &url.URL{
+		Scheme: host.Scheme,
+		Host:   host.Host,
+		Path:   "/",
+	}
 
-		// make request
-		request, err := http.NewRequest("GET", index.Loc, nil)
+	robotFile, err := NewRobotFileFromURL(root.ResolveReference(&url.URL{Path: "/robots.txt"}), client)
+	if err != nil {
+		return nil, err
+	}
+	if sitemaps := robotFile.Sitemaps(); len(sitemaps) > 0 {
+		return sitemaps, nil
+	}
+
+	sitemaps := make([]*url.URL, 0)
+	for _, path := range sitemapProbePaths {
+		location := root.ResolveReference(&url.URL{Path: path})
+		ok, err := sitemapExists(location, client)
 		if err != nil {
 			return nil, err
 		}
-		res, err := client.RoundTrip(request)
-		defer res.Body.Close()
+		if ok {
+			sitemaps = append(sitemaps, location)
+		}
+	}
 
-		// unmarshal sitemap
-		var sitemap Sitemap
-		data, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, err
+	links, err := sitemapLinks(root, client, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		if !containsURL(sitemaps, link) {
+			sitemaps = append(sitemaps, link)
 		}
-		err = xml.Unmarshal(data, &sitemap)
+	}
+	return sitemaps, nil
+}
+
+// sitemapExists returns true if the location responds with a 2xx status code.
+func sitemapExists(location *url.URL, client http.RoundTripper) (bool, error) {
+	request, err := http.NewRequest("GET", location.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := client.RoundTrip(request)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	return res.StatusCode >= 200 && res.StatusCode < 300, nil
+}
+
+// sitemapLinks returns the targets of all <link rel="sitemap"> elements in the first maxSize bytes of a page.
+// Returns no links if the page could not be retrieved.
+func sitemapLinks(page *url.URL, client http.RoundTripper, maxSize int64) ([]*url.URL, error) {
+	request, err := http.NewRequest("GET", page.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, nil
+	}
+
+	var body io.Reader = res.Body
+	if maxSize > 0 {
+		body = io.LimitReader(res.Body, maxSize)
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*url.URL, 0)
+	doc.Find("link[href]").Each(func(_ int, link *goquery.Selection) {
+		rel, _ := link.Attr("rel")
+		if !hasLinkType(rel, "sitemap") {
+			return
+		}
+		href, _ := link.Attr("href")
+		location, err := page.Parse(href)
 		if err != nil {
-			return nil, err
+			return
+		}
+		links = append(links, location)
+	})
+	return links, nil
+}
+
+// hasLinkType returns true if the space-separated rel attribute contains the link type, ignoring case.
+func hasLinkType(rel, linkType string) bool {
+	for _, field := range strings.Fields(rel) {
+		if strings.EqualFold(field, linkType) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsURL(urls []*url.URL, u *url.URL) bool {
+	for _, other := range urls {
+		if other.String() == u.String() {
+			return true
 		}
+	}
+	return false
+}
 
-		urls = append(urls, sitemap.URLSet...)
+// GetLocations gets up to <limit> page locations, including those in the sitemaps listed in the index.
+// Nested sitemap indexes are followed up to DefaultSitemapDepth, use a SitemapWalker for more control.
+// Sitemaps that could not be read are skipped, their errors are returned as SitemapErrors along with the other locations.
+func (s *Sitemap) GetLocations(client http.RoundTripper, limit int) ([]SitemapLocation, error) {
+	urls := make([]SitemapLocation, 0, len(s.URLSet))
+	for _, location := range s.URLSet {
+		if len(urls) >= limit {
+			return urls, nil
+		}
+		urls = append(urls, location)
 	}
 
-	return urls, nil
+	index := make([]string, len(s.Index))
+	for i, location := range s.Index {
+		index[i] = location.Loc
+	}
+	walker := NewSitemapWalker(client)
+	// the index itself was read already
+	walker.MaxDepth--
+	err := walker.Walk(func(location SitemapLocation) bool {
+		if len(urls) >= limit {
+			return false
+		}
+		urls = append(urls, location)
+		return true
+	}, index...)
+	return urls, err
 }
@@ -0,0 +1,226 @@
+package robots
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XML namespaces of the sitemap extensions.
+const (
+	SitemapImageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+	SitemapVideoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+	SitemapNewsNamespace  = "http://www.google.com/schemas/sitemap-news/0.9"
+	XHTMLNamespace        = "http://www.w3.org/1999/xhtml"
+)
+
+// SitemapImage is an image on a page, listed using the image sitemap extension.
+type SitemapImage struct {
+	Loc         string
+	Title       string
+	Caption     string
+	GeoLocation string
+	License     string
+}
+
+// SitemapVideo is a video on a page, listed using the video sitemap extension.
+type SitemapVideo struct {
+	ThumbnailLoc         string
+	Title                string
+	Description          string
+	ContentLoc           string
+	PlayerLoc            string
+	Duration             time.Duration
+	ExpirationDate       time.Time
+	PublicationDate      time.Time
+	Rating               float64
+	ViewCount            int
+	FamilyFriendly       bool
+	RequiresSubscription bool
+	Live                 bool
+	Tags                 []string
+	Category             string
+	Uploader             string
+	// Restriction holds the countries in which the video may or may not be played.
+	Restriction *SitemapVideoRestriction
+	// Platform holds the platforms on which the video may or may not be played.
+	Platform *SitemapVideoRestriction
+}
+
+// SitemapVideoRestriction allows or denies a video for a space-separated list of countries or platforms.
+type SitemapVideoRestriction struct {
+	Allow  bool
+	Values []string
+}
+
+// SitemapNews is a news article, listed using the news sitemap extension.
+type SitemapNews struct {
+	PublicationName     string
+	PublicationLanguage string
+	PublicationDate     time.Time
+	Title               string
+	Keywords            []string
+	StockTickers        []string
+}
+
+// SitemapAlternate is a localized version of a page, listed using xhtml:link elements.
+type SitemapAlternate struct {
+	HrefLang string
+	Href     string
+}
+
+type xmlSitemapImage struct {
+	Loc         string `xml:"http://www.google.com/schemas/sitemap-image/1.1 loc"`
+	Title       string `xml:"http://www.google.com/schemas/sitemap-image/1.1 title"`
+	Caption     string `xml:"http://www.google.com/schemas/sitemap-image/1.1 caption"`
+	GeoLocation string `xml:"http://www.google.com/schemas/sitemap-image/1.1 geo_location"`
+	License     string `xml:"http://www.google.com/schemas/sitemap-image/1.1 license"`
+}
+
+type xmlSitemapVideoRestriction struct {
+	Relationship string `xml:"relationship,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type xmlSitemapVideo struct {
+	ThumbnailLoc         string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 thumbnail_loc"`
+	Title                string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 title"`
+	Description          string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 description"`
+	ContentLoc           string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 content_loc"`
+	PlayerLoc            string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 player_loc"`
+	Duration             string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 duration"`
+	ExpirationDate       string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 expiration_date"`
+	PublicationDate      string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 publication_date"`
+	Rating               string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 rating"`
+	ViewCount            string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 view_count"`
+	FamilyFriendly       string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 family_friendly"`
+	RequiresSubscription string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 requires_subscription"`
+	Live                 string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 live"`
+	Tags                 []string                    `xml:"http://www.google.com/schemas/sitemap-video/1.1 tag"`
+	Category             string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 category"`
+	Uploader             string                      `xml:"http://www.google.com/schemas/sitemap-video/1.1 uploader"`
+	Restriction          *xmlSitemapVideoRestriction `xml:"http://www.google.com/schemas/sitemap-video/1.1 restriction"`
+	Platform             *xmlSitemapVideoRestriction `xml:"http://www.google.com/schemas/sitemap-video/1.1 platform"`
+}
+
+type xmlSitemapNews struct {
+	PublicationName     string `xml:"http://www.google.com/schemas/sitemap-news/0.9 publication>name"`
+	PublicationLanguage string `xml:"http://www.google.com/schemas/sitemap-news/0.9 publication>language"`
+	PublicationDate     string `xml:"http://www.google.com/schemas/sitemap-news/0.9 publication_date"`
+	Title               string `xml:"http://www.google.com/schemas/sitemap-news/0.9 title"`
+	Keywords            string `xml:"http://www.google.com/schemas/sitemap-news/0.9 keywords"`
+	StockTickers        string `xml:"http://www.google.com/schemas/sitemap-news/0.9 stock_tickers"`
+}
+
+type xmlSitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// sitemapExtensions holds the extension elements of a urlset entry.
+type sitemapExtensions struct {
+	Images     []xmlSitemapImage     `xml:"http://www.google.com/schemas/sitemap-image/1.1 image"`
+	Videos     []xmlSitemapVideo     `xml:"http://www.google.com/schemas/sitemap-video/1.1 video"`
+	News       *xmlSitemapNews       `xml:"http://www.google.com/schemas/sitemap-news/0.9 news"`
+	Alternates []xmlSitemapAlternate `xml:"http://www.w3.org/1999/xhtml link"`
+}
+
+// apply adds the parsed extensions to a location.
+func (e *sitemapExtensions) apply(location *SitemapLocation) {
+	for _, image := range e.Images {
+		location.Images = append(location.Images, SitemapImage{
+			Loc:         strings.TrimSpace(image.Loc),
+			Title:       strings.TrimSpace(image.Title),
+			Caption:     strings.TrimSpace(image.Caption),
+			GeoLocation: strings.TrimSpace(image.GeoLocation),
+			License:     strings.TrimSpace(image.License),
+		})
+	}
+
+	for _, video := range e.Videos {
+		parsed := SitemapVideo{
+			ThumbnailLoc:         strings.TrimSpace(video.ThumbnailLoc),
+			Title:                strings.TrimSpace(video.Title),
+			Description:          strings.TrimSpace(video.Description),
+			ContentLoc:           strings.TrimSpace(video.ContentLoc),
+			PlayerLoc:            strings.TrimSpace(video.PlayerLoc),
+			ExpirationDate:       parseSitemapTime(video.ExpirationDate, w3cTimeLayouts),
+			PublicationDate:      parseSitemapTime(video.PublicationDate, w3cTimeLayouts),
+			FamilyFriendly:       parseYesNo(video.FamilyFriendly, true),
+			RequiresSubscription: parseYesNo(video.RequiresSubscription, false),
+			Live:                 parseYesNo(video.Live, false),
+			Category:             strings.TrimSpace(video.Category),
+			Uploader:             strings.TrimSpace(video.Uploader),
+			Restriction:          video.Restriction.parse(),
+			Platform:             video.Platform.parse(),
+		}
+		if seconds, err := strconv.Atoi(strings.TrimSpace(video.Duration)); err == nil {
+			parsed.Duration = time.Duration(seconds) * time.Second
+		}
+		if rating, err := strconv.ParseFloat(strings.TrimSpace(video.Rating), 64); err == nil {
+			parsed.Rating = rating
+		}
+		if viewCount, err := strconv.Atoi(strings.TrimSpace(video.ViewCount)); err == nil {
+			parsed.ViewCount = viewCount
+		}
+		for _, tag := range video.Tags {
+			parsed.Tags = append(parsed.Tags, strings.TrimSpace(tag))
+		}
+		location.Videos = append(location.Videos, parsed)
+	}
+
+	if e.News != nil {
+		location.News = &SitemapNews{
+			PublicationName:     strings.TrimSpace(e.News.PublicationName),
+			PublicationLanguage: strings.TrimSpace(e.News.PublicationLanguage),
+			PublicationDate:     parseSitemapTime(e.News.PublicationDate, w3cTimeLayouts),
+			Title:               strings.TrimSpace(e.News.Title),
+			Keywords:            splitList(e.News.Keywords),
+			StockTickers:        splitList(e.News.StockTickers),
+		}
+	}
+
+	for _, alternate := range e.Alternates {
+		if alternate.Rel != "alternate" {
+			continue
+		}
+		location.Alternates = append(location.Alternates, SitemapAlternate{
+			HrefLang: alternate.HrefLang,
+			Href:     strings.TrimSpace(alternate.Href),
+		})
+	}
+}
+
+func (r *xmlSitemapVideoRestriction) parse() *SitemapVideoRestriction {
+	if r == nil {
+		return nil
+	}
+	return &SitemapVideoRestriction{
+		Allow:  r.Relationship != "deny",
+		Values: strings.Fields(r.Value),
+	}
+}
+
+// parseYesNo parses a yes/no value, returns the default if it is missing or invalid.
+func parseYesNo(value string, defaultValue bool) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes":
+		return true
+	case "no":
+		return false
+	default:
+		return defaultValue
+	}
+}
+
+// splitList splits a comma-separated list, trimming any whitespace.
+func splitList(value string) []string {
+	values := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
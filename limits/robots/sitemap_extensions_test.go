@@ -0,0 +1,101 @@
+package robots_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+)
+
+var extensionSitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+	xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"
+	xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"
+	xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"
+	xmlns:xhtml="http://www.w3.org/1999/xhtml">
+	<url>
+		<loc>https://www.example.com/videos/grilling-steaks</loc>
+		<image:image>
+			<image:loc>https://www.example.com/image.jpg</image:loc>
+		</image:image>
+		<image:image>
+			<image:loc>https://www.example.com/photo.jpg</image:loc>
+			<image:caption>A photo</image:caption>
+		</image:image>
+		<video:video>
+			<video:thumbnail_loc>https://www.example.com/thumbs/123.jpg</video:thumbnail_loc>
+			<video:title>Grilling steaks for summer</video:title>
+			<video:description>Alkis shows you how to get perfectly done steaks every time</video:description>
+			<video:content_loc>http://streamserver.example.com/video123.mp4</video:content_loc>
+			<video:duration>600</video:duration>
+			<video:rating>4.2</video:rating>
+			<video:view_count>12345</video:view_count>
+			<video:publication_date>2007-11-05T19:20:30+08:00</video:publication_date>
+			<video:family_friendly>no</video:family_friendly>
+			<video:restriction relationship="allow">IE GB US CA</video:restriction>
+			<video:tag>steak</video:tag>
+			<video:tag>summer</video:tag>
+			<video:live>no</video:live>
+		</video:video>
+	</url>
+	<url>
+		<loc>https://www.example.org/business/article55.html</loc>
+		<news:news>
+			<news:publication>
+				<news:name>The Example Times</news:name>
+				<news:language>en</news:language>
+			</news:publication>
+			<news:publication_date>2008-12-23</news:publication_date>
+			<news:title>Companies A, B in Merger Talks</news:title>
+			<news:keywords>business, merger, acquisition</news:keywords>
+		</news:news>
+		<xhtml:link rel="alternate" hreflang="de" href="https://www.example.org/deutsch/article55.html"/>
+		<xhtml:link rel="alternate" hreflang="en" href="https://www.example.org/business/article55.html"/>
+	</url>
+</urlset>`
+
+func TestSitemapExtensions(t *testing.T) {
+	sitemap, err := robots.NewSitemapFromReader(strings.NewReader(extensionSitemap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sitemap.URLSet) != 2 {
+		t.Fatalf("expected 2 locations, got %d", len(sitemap.URLSet))
+	}
+
+	video := sitemap.URLSet[0]
+	if len(video.Images) != 2 || video.Images[1].Loc != "https://www.example.com/photo.jpg" || video.Images[1].Caption != "A photo" {
+		t.Fatalf("invalid images %v", video.Images)
+	}
+	if len(video.Videos) != 1 {
+		t.Fatalf("expected 1 video, got %d", len(video.Videos))
+	}
+	v := video.Videos[0]
+	if v.Title != "Grilling steaks for summer" || v.Duration != 10*time.Minute || v.Rating != 4.2 || v.ViewCount != 12345 {
+		t.Fatalf("invalid video %+v", v)
+	}
+	if v.FamilyFriendly || v.Live || len(v.Tags) != 2 {
+		t.Fatalf("invalid video %+v", v)
+	}
+	if v.Restriction == nil || !v.Restriction.Allow || len(v.Restriction.Values) != 4 {
+		t.Fatalf("invalid video restriction %+v", v.Restriction)
+	}
+	if v.PublicationDate.IsZero() {
+		t.Fatal("video publication date not parsed")
+	}
+
+	article := sitemap.URLSet[1]
+	if article.News == nil {
+		t.Fatal("news not parsed")
+	}
+	if article.News.PublicationName != "The Example Times" || article.News.PublicationLanguage != "en" || len(article.News.Keywords) != 3 {
+		t.Fatalf("invalid news %+v", article.News)
+	}
+	if !article.News.PublicationDate.Equal(time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("invalid news publication date %s", article.News.PublicationDate)
+	}
+	if len(article.Alternates) != 2 || article.Alternates[0].HrefLang != "de" || article.Alternates[0].Href != "https://www.example.org/deutsch/article55.html" {
+		t.Fatalf("invalid alternates %v", article.Alternates)
+	}
+}
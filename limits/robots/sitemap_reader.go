@@ -0,0 +1,323 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSitemapLocations is the maximum amount of locations in a single sitemap file.
+const MaxSitemapLocations = 50000
+
+// MaxSitemapSize is the maximum uncompressed size of a single sitemap file in bytes.
+const MaxSitemapSize = 50 * 1024 * 1024
+
+// SitemapFormat is the format of a sitemap file.
+type SitemapFormat int
+
+const (
+	// SitemapURLSet is an XML sitemap listing pages.
+	SitemapURLSet SitemapFormat = iota
+	// SitemapIndex is an XML sitemap listing other sitemaps.
+	SitemapIndex
+	// SitemapText is a plain text sitemap listing one page per line.
+	SitemapText
+	// SitemapRSS is an RSS 2.0 feed used as a sitemap.
+	SitemapRSS
+	// SitemapAtom is an Atom feed used as a sitemap.
+	SitemapAtom
+)
+
+// xmlSitemapLocation is the XML representation of a urlset or sitemapindex entry.
+// Dates and priorities are parsed separately so invalid values can be ignored.
+type xmlSitemapLocation struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+	sitemapExtensions
+}
+
+// rssItem is the XML representation of an RSS 2.0 item.
+type rssItem struct {
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+}
+
+// atomEntry is the XML representation of an Atom entry.
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated string `xml:"updated"`
+}
+
+// SitemapReader reads the locations in a sitemap one by one, without loading the entire sitemap into memory.
+// XML sitemaps and sitemap indexes, plain text sitemaps and RSS 2.0 or Atom feeds are supported,
+// gzip compressed sitemaps are decompressed transparently.
+type SitemapReader struct {
+	format  SitemapFormat
+	limit   *sizeLimitReader
+	decoder *xml.Decoder
+	scanner *bufio.Scanner
+	count   int
+	err     error
+}
+
+// NewSitemapReader instantiates a new sitemap reader, detecting the sitemap's format and compression.
+// Returns an error if the sitemap could not be read.
+func NewSitemapReader(in io.Reader) (*SitemapReader, error) {
+	buffered := bufio.NewReader(in)
+	magic, _ := buffered.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReader(decompressed)
+	}
+
+	limit := &sizeLimitReader{reader: buffered, remaining: MaxSitemapSize}
+	reader := &SitemapReader{limit: limit}
+	if !isXML(buffered) {
+		reader.format = SitemapText
+		reader.scanner = bufio.NewScanner(limit)
+		return reader, nil
+	}
+
+	reader.decoder = xml.NewDecoder(limit)
+	for {
+		token, err := reader.decoder.Token()
+		if err != nil {
+			return nil, reader.wrapError(err)
+		}
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch root.Name.Local {
+		case "sitemapindex":
+			reader.format = SitemapIndex
+		case "rss":
+			reader.format = SitemapRSS
+		case "feed":
+			reader.format = SitemapAtom
+		default:
+			reader.format = SitemapURLSet
+		}
+		return reader, nil
+	}
+}
+
+// Format returns the format of the sitemap.
+func (r *SitemapReader) Format() SitemapFormat {
+	return r.format
+}
+
+// IsIndex returns true if the sitemap lists other sitemaps instead of pages.
+func (r *SitemapReader) IsIndex() bool {
+	return r.format == SitemapIndex
+}
+
+// Next returns the next location in the sitemap.
+// Returns io.EOF when there are no more locations.
+// Returns SitemapLimitExceeded if the sitemap exceeds MaxSitemapLocations or MaxSitemapSize,
+// the locations read before the limit was reached are valid.
+func (r *SitemapReader) Next() (SitemapLocation, error) {
+	if r.err != nil {
+		return SitemapLocation{}, r.err
+	}
+
+	var location SitemapLocation
+	var err error
+	if r.format == SitemapText {
+		location, err = r.nextLine()
+	} else {
+		location, err = r.nextElement()
+	}
+	if err == nil && r.count >= MaxSitemapLocations {
+		err = SitemapLimitExceeded{Limit: strconv.Itoa(MaxSitemapLocations) + " locations"}
+	}
+	if err != nil {
+		r.err = r.wrapError(err)
+		return SitemapLocation{}, r.err
+	}
+	r.count++
+	return location, nil
+}
+
+// nextLine returns the location on the next non-empty line of a text sitemap, lines that are not absolute URLs are ignored.
+func (r *SitemapReader) nextLine() (SitemapLocation, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(r.scanner.Text(), "\ufeff"))
+		if u, err := url.Parse(line); err != nil || !u.IsAbs() {
+			continue
+		}
+		return SitemapLocation{Loc: line, Priority: DefaultSitemapPriority}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return SitemapLocation{}, err
+	}
+	return SitemapLocation{}, io.EOF
+}
+
+// nextElement decodes the next url, sitemap, item or entry element of an XML sitemap.
+func (r *SitemapReader) nextElement() (SitemapLocation, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return SitemapLocation{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case r.format == SitemapURLSet && start.Name.Local == "url",
+			r.format == SitemapIndex && start.Name.Local == "sitemap":
+			element := xmlSitemapLocation{}
+			if err := r.decoder.DecodeElement(&element, &start); err != nil {
+				return SitemapLocation{}, err
+			}
+			location := SitemapLocation{
+				Loc:        strings.TrimSpace(element.Loc),
+				LastMod:    parseSitemapTime(element.LastMod, w3cTimeLayouts),
+				ChangeFreq: strings.TrimSpace(element.ChangeFreq),
+			}
+			if r.format == SitemapURLSet {
+				location.Priority = parsePriority(element.Priority)
+				element.sitemapExtensions.apply(&location)
+			}
+			return location, nil
+
+		case r.format == SitemapRSS && start.Name.Local == "item":
+			item := rssItem{}
+			if err := r.decoder.DecodeElement(&item, &start); err != nil {
+				return SitemapLocation{}, err
+			}
+			return SitemapLocation{
+				Loc:      strings.TrimSpace(item.Link),
+				LastMod:  parseSitemapTime(item.PubDate, rssTimeLayouts),
+				Priority: DefaultSitemapPriority,
+			}, nil
+
+		case r.format == SitemapAtom && start.Name.Local == "entry":
+			entry := atomEntry{}
+			if err := r.decoder.DecodeElement(&entry, &start); err != nil {
+				return SitemapLocation{}, err
+			}
+			location := SitemapLocation{
+				LastMod:  parseSitemapTime(entry.Updated, w3cTimeLayouts),
+				Priority: DefaultSitemapPriority,
+			}
+			for _, link := range entry.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					location.Loc = strings.TrimSpace(link.Href)
+					break
+				}
+			}
+			return location, nil
+		}
+	}
+}
+
+// wrapError replaces errors caused by exceeding the size limit with SitemapLimitExceeded.
+// The XML decoder and scanner may wrap or replace the error returned by the size limit reader.
+func (r *SitemapReader) wrapError(err error) error {
+	if r.limit.exceeded {
+		return SitemapLimitExceeded{Limit: strconv.Itoa(MaxSitemapSize) + " bytes"}
+	}
+	return err
+}
+
+// DefaultSitemapPriority is the priority of sitemap locations that do not specify one.
+const DefaultSitemapPriority = 0.5
+
+// w3cTimeLayouts are the W3C datetime layouts allowed in sitemaps and Atom feeds.
+var w3cTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// rssTimeLayouts are the RFC 822 datetime layouts used in RSS feeds.
+var rssTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+// parseSitemapTime parses a time in any of the given layouts, returns the zero time if it could not be parsed.
+func parseSitemapTime(value string, layouts []string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parsePriority parses a sitemap priority, returns DefaultSitemapPriority if it is missing or invalid.
+func parsePriority(value string) float64 {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || priority < 0 || priority > 1 {
+		return DefaultSitemapPriority
+	}
+	return priority
+}
+
+// isXML returns true if the first non-whitespace character of the stream is '<'.
+func isXML(reader *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		peeked, err := reader.Peek(n)
+		if len(peeked) < n {
+			return false
+		}
+		trimmed := bytes.TrimLeft(bytes.TrimPrefix(peeked, []byte("\ufeff")), " \t\r\n")
+		if len(trimmed) > 0 {
+			return trimmed[0] == '<'
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// sizeLimitReader reads from a reader until a limit is exceeded.
+type sizeLimitReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		r.exceeded = true
+		return 0, SitemapLimitExceeded{Limit: strconv.Itoa(MaxSitemapSize) + " bytes"}
+	}
+	// read one byte past the limit to detect files exceeding it
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		r.exceeded = true
+		return n, SitemapLimitExceeded{Limit: strconv.Itoa(MaxSitemapSize) + " bytes"}
+	}
+	return n, err
+}
@@ -1,8 +1,18 @@
 package robots_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/KillianMeersman/wander/limits/robots"
 )
@@ -29,3 +39,208 @@ func TestSitemapParsing(t *testing.T) {
 		t.Fatal("URLSet empty")
 	}
 }
+
+func TestRobotFileSitemaps(t *testing.T) {
+	robotFile, err := robots.NewRobotFileFromReader(strings.NewReader(`
+Sitemap: https://example.com/sitemap-1.xml
+User-agent: *
+Disallow: /private
+Sitemap: https://example.com/sitemap-2.xml
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sitemaps := robotFile.Sitemaps()
+	if len(sitemaps) != 2 {
+		t.Fatalf("expected 2 sitemaps, got %d", len(sitemaps))
+	}
+	if sitemaps[0].Path != "/sitemap-1.xml" || sitemaps[1].Path != "/sitemap-2.xml" {
+		t.Fatalf("sitemaps not in listed order: %v", sitemaps)
+	}
+}
+
+func TestDiscoverSitemaps(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<urlset></urlset>"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><head>
+<link rel="Sitemap" type="application/xml" href="/maps/main.xml">
+<link rel="sitemap" href="/sitemap.xml">
+<link rel="stylesheet" href="/style.css">
+</head><body>`))
+		// links beyond the page size limit are not read
+		w.Write(bytes.Repeat([]byte(" "), 1024))
+		w.Write([]byte(`<link rel="sitemap" href="/maps/late.xml"></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	host, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemaps, err := robots.DiscoverSitemaps(host, http.DefaultTransport, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{server.URL + "/sitemap.xml", server.URL + "/maps/main.xml"}
+	if len(sitemaps) != len(expected) {
+		t.Fatalf("expected sitemaps %v, got %v", expected, sitemaps)
+	}
+	for i, sitemap := range sitemaps {
+		if sitemap.String() != expected[i] {
+			t.Fatalf("expected sitemaps %v, got %v", expected, sitemaps)
+		}
+	}
+}
+
+func readSitemap(t *testing.T, reader *robots.SitemapReader) []robots.SitemapLocation {
+	locations := make([]robots.SitemapLocation, 0)
+	for {
+		location, err := reader.Next()
+		if err == io.EOF {
+			return locations
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		locations = append(locations, location)
+	}
+}
+
+func TestSitemapReaderURLSet(t *testing.T) {
+	urlsetFile, err := os.Open("urlset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer urlsetFile.Close()
+
+	reader, err := robots.NewSitemapReader(urlsetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Format() != robots.SitemapURLSet {
+		t.Fatalf("expected urlset, got format %d", reader.Format())
+	}
+
+	locations := readSitemap(t, reader)
+	if len(locations) != 3 {
+		t.Fatalf("expected 3 locations, got %d", len(locations))
+	}
+	if locations[1].Loc != "https://www.example.com/catalog?item=12&desc=vacation_hawaii" {
+		t.Fatalf("invalid location %s", locations[1].Loc)
+	}
+	if !locations[1].LastMod.Equal(time.Date(2020, 6, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("invalid lastmod %s", locations[1].LastMod)
+	}
+	if locations[0].Priority != 1 || locations[1].Priority != robots.DefaultSitemapPriority || locations[2].Priority != 0.3 {
+		t.Fatal("invalid priorities")
+	}
+}
+
+func TestSitemapReaderGzip(t *testing.T) {
+	urlset, err := ioutil.ReadFile("urlset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	writer.Write(urlset)
+	writer.Close()
+
+	reader, err := robots.NewSitemapReader(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locations := readSitemap(t, reader); len(locations) != 3 {
+		t.Fatalf("expected 3 locations, got %d", len(locations))
+	}
+}
+
+func TestSitemapReaderFormats(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		format robots.SitemapFormat
+	}{
+		{"text", "\nhttps://example.com/a\nnot a url\n  https://example.com/b  \n", robots.SitemapText},
+		{"rss", `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title><link>https://example.com/</link>
+<item><link>https://example.com/a</link><pubDate>Sat, 20 Jun 2020 03:16:10 +0200</pubDate></item>
+<item><link>https://example.com/b</link></item>
+</channel></rss>`, robots.SitemapRSS},
+		{"atom", `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Example</title><link href="https://example.com/"/>
+<entry><link rel="alternate" href="https://example.com/a"/><updated>2020-06-20T03:16:10+02:00</updated></entry>
+<entry><link rel="edit" href="https://example.com/edit/b"/><link href="https://example.com/b"/></entry>
+</feed>`, robots.SitemapAtom},
+	}
+
+	for _, c := range cases {
+		reader, err := robots.NewSitemapReader(strings.NewReader(c.body))
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if reader.Format() != c.format {
+			t.Fatalf("%s: expected format %d, got %d", c.name, c.format, reader.Format())
+		}
+		locations := readSitemap(t, reader)
+		if len(locations) != 2 || locations[0].Loc != "https://example.com/a" || locations[1].Loc != "https://example.com/b" {
+			t.Fatalf("%s: invalid locations %v", c.name, locations)
+		}
+	}
+}
+
+func TestSitemapReaderLimits(t *testing.T) {
+	text := &bytes.Buffer{}
+	for i := 0; i <= robots.MaxSitemapLocations; i++ {
+		fmt.Fprintf(text, "https://example.com/%d\n", i)
+	}
+
+	reader, err := robots.NewSitemapReader(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		_, err := reader.Next()
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(robots.SitemapLimitExceeded); !ok {
+			t.Fatalf("expected SitemapLimitExceeded, got %v", err)
+		}
+		if i != robots.MaxSitemapLocations {
+			t.Fatalf("limit exceeded after %d locations", i)
+		}
+		break
+	}
+
+	large := io.MultiReader(
+		strings.NewReader("<urlset><url><loc>https://example.com/</loc></url><!-- "),
+		bytes.NewReader(bytes.Repeat([]byte("x"), robots.MaxSitemapSize)),
+		strings.NewReader(" --></urlset>"),
+	)
+	reader, err = robots.NewSitemapReader(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); err == nil {
+		t.Fatal("expected an error for an oversized sitemap")
+	} else if _, ok := err.(robots.SitemapLimitExceeded); !ok {
+		t.Fatalf("expected SitemapLimitExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package robots
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultSitemapDepth is the default maximum depth of nested sitemap indexes followed by a SitemapWalker.
+const DefaultSitemapDepth = 3
+
+// SitemapWalker reads sitemaps and the sitemaps listed in sitemap indexes, recursively.
+// Each sitemap is read at most once per walker, so cycles between sitemap indexes are not followed.
+// Sitemaps are downloaded using the walker's client, pass a Spider to respect its throttles.
+type SitemapWalker struct {
+	client http.RoundTripper
+	// MaxDepth is the maximum amount of nested sitemap indexes that are followed, 0 only reads the given sitemaps.
+	MaxDepth int
+	// Since filters out locations last modified before it, locations without a last modification time are always included.
+	// Sitemaps listed in an index that were last modified before it are not downloaded.
+	Since   time.Time
+	visited map[string]struct{}
+}
+
+// NewSitemapWalker instantiates a new sitemap walker following up to DefaultSitemapDepth nested sitemap indexes.
+func NewSitemapWalker(client http.RoundTripper) *SitemapWalker {
+	return &SitemapWalker{
+		client:   client,
+		MaxDepth: DefaultSitemapDepth,
+		visited:  make(map[string]struct{}),
+	}
+}
+
+// Walk reads the sitemaps at the given locations, calling fn for each page location they list, directly or through sitemap indexes.
+// Walking stops when fn returns false.
+// Sitemaps that could not be read are skipped, returns SitemapErrors holding their errors if there were any.
+func (w *SitemapWalker) Walk(fn func(SitemapLocation) bool, locations ...string) error {
+	errs := make(SitemapErrors, 0)
+	w.walk(locations, 0, fn, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// walk reads the sitemaps at the given depth, returns false if walking was stopped by fn.
+func (w *SitemapWalker) walk(locations []string, depth int, fn func(SitemapLocation) bool, errs *SitemapErrors) bool {
+	for _, location := range locations {
+		if _, ok := w.visited[location]; ok {
+			continue
+		}
+		w.visited[location] = struct{}{}
+
+		children, ok, err := w.read(location, fn)
+		if err != nil {
+			*errs = append(*errs, SitemapError{URL: location, Err: err})
+		}
+		if !ok {
+			return false
+		}
+		if len(children) < 1 {
+			continue
+		}
+
+		if depth >= w.MaxDepth {
+			*errs = append(*errs, SitemapError{URL: location, Err: fmt.Errorf("Sitemap index exceeds maximum depth of %d", w.MaxDepth)})
+			continue
+		}
+		if !w.walk(children, depth+1, fn, errs) {
+			return false
+		}
+	}
+	return true
+}
+
+// read reads a single sitemap, calling fn for each page location.
+// Returns the locations of the listed sitemaps if it is a sitemap index and false if walking was stopped by fn.
+// Locations read before an error occurred are kept.
+func (w *SitemapWalker) read(location string, fn func(SitemapLocation) bool) ([]string, bool, error) {
+	request, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	res, err := w.client.RoundTrip(request)
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, true, fmt.Errorf("Sitemap request returned status code %d", res.StatusCode)
+	}
+
+	reader, err := NewSitemapReader(res.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	children := make([]string, 0)
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return children, true, nil
+		}
+		if err != nil {
+			return children, true, err
+		}
+		if !w.Since.IsZero() && !entry.LastMod.IsZero() && entry.LastMod.Before(w.Since) {
+			continue
+		}
+
+		if reader.IsIndex() {
+			children = append(children, entry.Loc)
+		} else if !fn(entry) {
+			return nil, false, nil
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package robots_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+)
+
+func sitemapServer() *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	index := func(children ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "<sitemapindex>")
+			for _, child := range children {
+				fmt.Fprintf(w, "<sitemap><loc>%s%s</loc><lastmod>2020-06-20</lastmod></sitemap>", server.URL, child)
+			}
+			fmt.Fprint(w, "</sitemapindex>")
+		}
+	}
+	mux.HandleFunc("/index.xml", index("/nested.xml", "/a.xml", "/missing.xml", "/broken.xml"))
+	mux.HandleFunc("/nested.xml", index("/a.xml", "/index.xml", "/b.xml"))
+	mux.HandleFunc("/a.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset>
+<url><loc>https://example.com/a/1</loc><lastmod>2020-01-01</lastmod></url>
+<url><loc>https://example.com/a/2</loc><lastmod>2020-06-01</lastmod></url>
+</urlset>`)
+	})
+	mux.HandleFunc("/b.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "https://example.com/b/1\nhttps://example.com/b/2\n")
+	})
+	mux.HandleFunc("/broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<urlset><url><loc>https://example.com/broken/1</loc></url><url>")
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func walkSitemaps(walker *robots.SitemapWalker, location string) ([]string, error) {
+	locations := make([]string, 0)
+	err := walker.Walk(func(location robots.SitemapLocation) bool {
+		locations = append(locations, location.Loc)
+		return true
+	}, location)
+	sort.Strings(locations)
+	return locations, err
+}
+
+func TestSitemapWalker(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+
+	locations, err := walkSitemaps(robots.NewSitemapWalker(http.DefaultTransport), server.URL+"/index.xml")
+	expected := []string{
+		"https://example.com/a/1",
+		"https://example.com/a/2",
+		"https://example.com/b/1",
+		"https://example.com/b/2",
+		"https://example.com/broken/1",
+	}
+	if fmt.Sprint(locations) != fmt.Sprint(expected) {
+		t.Fatalf("expected locations %v, got %v", expected, locations)
+	}
+
+	errs, ok := err.(robots.SitemapErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 sitemap errors, got %v", err)
+	}
+	if errs[0].URL != server.URL+"/missing.xml" || errs[1].URL != server.URL+"/broken.xml" {
+		t.Fatalf("unexpected sitemap errors %v", errs)
+	}
+}
+
+func TestSitemapWalkerDepth(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+
+	walker := robots.NewSitemapWalker(http.DefaultTransport)
+	walker.MaxDepth = 0
+	locations, err := walkSitemaps(walker, server.URL+"/index.xml")
+	if len(locations) != 0 {
+		t.Fatalf("nested sitemaps read beyond maximum depth: %v", locations)
+	}
+	if errs, ok := err.(robots.SitemapErrors); !ok || len(errs) != 1 {
+		t.Fatalf("expected a depth error, got %v", err)
+	}
+}
+
+func TestSitemapWalkerSince(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+
+	walker := robots.NewSitemapWalker(http.DefaultTransport)
+	walker.Since = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	locations, _ := walkSitemaps(walker, server.URL+"/a.xml")
+	if len(locations) != 1 || locations[0] != "https://example.com/a/2" {
+		t.Fatalf("expected only locations modified since %s, got %v", walker.Since, locations)
+	}
+
+	// sitemaps listed in an index as modified before Since are skipped
+	walker = robots.NewSitemapWalker(http.DefaultTransport)
+	walker.Since = time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	locations, err := walkSitemaps(walker, server.URL+"/index.xml")
+	if err != nil || len(locations) != 0 {
+		t.Fatalf("expected no locations, got %v (%v)", locations, err)
+	}
+}
+
+func TestSitemapGetLocationsLimit(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+
+	sitemap, err := robots.NewSitemapFromURL(server.URL+"/nested.xml", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations, _ := sitemap.GetLocations(http.DefaultTransport, 3)
+	if len(locations) != 3 {
+		t.Fatalf("expected 3 locations, got %d", len(locations))
+	}
+}
+
+func TestSitemapFromURLStatus(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+
+	if _, err := robots.NewSitemapFromURL(server.URL+"/missing.xml", http.DefaultTransport); err == nil {
+		t.Fatal("expected an error for a missing sitemap")
+	}
+}
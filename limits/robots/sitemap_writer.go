@@ -0,0 +1,270 @@
+package robots
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SitemapNamespace is the XML namespace of sitemaps.
+const SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapIndexName is the name of the sitemap index written by a SitemapWriter, without extension.
+const SitemapIndexName = "sitemap_index"
+
+const (
+	urlsetHeader       = xml.Header + `<urlset xmlns="` + SitemapNamespace + `">` + "\n"
+	urlsetFooter       = "</urlset>\n"
+	sitemapIndexHeader = xml.Header + `<sitemapindex xmlns="` + SitemapNamespace + `">` + "\n"
+	sitemapIndexFooter = "</sitemapindex>\n"
+)
+
+// xmlWriteLocation is the XML representation of a written urlset or sitemapindex entry.
+type xmlWriteLocation struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// SitemapWriter writes page locations to sitemap files and lists these in a sitemap index.
+// A new sitemap file is started when the current one would exceed MaxSitemapLocations or MaxSitemapSize.
+// Sitemap files are named sitemap-1.xml, sitemap-2.xml... and listed in sitemap_index.xml when the writer is closed,
+// files are gzip compressed and get a .gz extension if Gzip is true.
+type SitemapWriter struct {
+	// Gzip compresses the written files if true.
+	Gzip    bool
+	baseURL *url.URL
+	create  func(name string) (io.WriteCloser, error)
+
+	// files holds the names of the completed sitemap files
+	files   []string
+	current *sitemapFile
+	closed  bool
+}
+
+// sitemapFile is a sitemap file being written.
+type sitemapFile struct {
+	name   string
+	file   io.WriteCloser
+	writer io.Writer
+	gzip   *gzip.Writer
+	count  int
+	size   int
+}
+
+// NewSitemapWriter instantiates a new sitemap writer creating files using the create function.
+// The base URL is the location the files will be served from, it is used to list the sitemap files in the index.
+func NewSitemapWriter(baseURL *url.URL, create func(name string) (io.WriteCloser, error)) *SitemapWriter {
+	return &SitemapWriter{
+		baseURL: baseURL,
+		create:  create,
+		files:   make([]string, 0),
+	}
+}
+
+// NewSitemapFileWriter instantiates a new sitemap writer creating files in the given directory.
+// The base URL is the location the directory will be served from, it is used to list the sitemap files in the index.
+func NewSitemapFileWriter(baseURL *url.URL, directory string) *SitemapWriter {
+	return NewSitemapWriter(baseURL, func(name string) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(directory, name))
+	})
+}
+
+// Add writes a location to the current sitemap file, empty fields are omitted.
+func (w *SitemapWriter) Add(location SitemapLocation) error {
+	element := xmlWriteLocation{
+		Loc:        location.Loc,
+		ChangeFreq: location.ChangeFreq,
+	}
+	if !location.LastMod.IsZero() {
+		element.LastMod = location.LastMod.Format(time.RFC3339)
+	}
+	if location.Priority > 0 {
+		element.Priority = strconv.FormatFloat(location.Priority, 'f', -1, 64)
+	}
+
+	entry, err := marshalEntry("url", element)
+	if err != nil {
+		return err
+	}
+
+	if w.current != nil && (w.current.count >= MaxSitemapLocations || w.current.size+len(entry)+len(urlsetFooter) > MaxSitemapSize) {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.current == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.current.writer.Write(entry)
+	if err != nil {
+		return err
+	}
+	w.current.count++
+	w.current.size += len(entry)
+	return nil
+}
+
+// AddURL writes a URL to the current sitemap file.
+func (w *SitemapWriter) AddURL(u *url.URL) error {
+	return w.Add(SitemapLocation{Loc: u.String()})
+}
+
+// CrawledPage is a response that can be added to a sitemap, implemented by request.Response.
+type CrawledPage interface {
+	// FinalURL returns the url of the page after following redirects.
+	FinalURL() *url.URL
+	// HTTPResponse returns the http response of the page.
+	HTTPResponse() *http.Response
+}
+
+// AddResponse writes the final URL of a response to the current sitemap file, using its Last-Modified header as the last modification time.
+// Redirected pages are written under the URL they redirected to. Responses without a 2xx status code are not written.
+func (w *SitemapWriter) AddResponse(res CrawledPage) error {
+	httpResponse := res.HTTPResponse()
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		return nil
+	}
+
+	location := SitemapLocation{Loc: res.FinalURL().String()}
+	if lastModified, err := http.ParseTime(httpResponse.Header.Get("Last-Modified")); err == nil {
+		location.LastMod = lastModified
+	}
+	return w.Add(location)
+}
+
+// Files returns the names of the sitemap files written so far, excluding the index.
+func (w *SitemapWriter) Files() []string {
+	return w.files
+}
+
+// Close completes the current sitemap file and writes the sitemap index listing all sitemap files.
+// Calling Close again has no effect.
+func (w *SitemapWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.current != nil {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	index, err := w.createFile(SitemapIndexName + w.extension())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(index.writer, sitemapIndexHeader)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, name := range w.files {
+		entry, err := marshalEntry("sitemap", xmlWriteLocation{
+			Loc:     w.baseURL.ResolveReference(&url.URL{Path: name}).String(),
+			LastMod: now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = index.writer.Write(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(index.writer, sitemapIndexFooter)
+	if err != nil {
+		return err
+	}
+	return index.close()
+}
+
+func (w *SitemapWriter) openFile() error {
+	file, err := w.createFile(fmt.Sprintf("sitemap-%d%s", len(w.files)+1, w.extension()))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(file.writer, urlsetHeader)
+	if err != nil {
+		return err
+	}
+	file.size = len(urlsetHeader)
+	w.current = file
+	return nil
+}
+
+func (w *SitemapWriter) closeFile() error {
+	_, err := io.WriteString(w.current.writer, urlsetFooter)
+	if err != nil {
+		return err
+	}
+	err = w.current.close()
+	if err != nil {
+		return err
+	}
+	w.files = append(w.files, w.current.name)
+	w.current = nil
+	return nil
+}
+
+func (w *SitemapWriter) createFile(name string) (*sitemapFile, error) {
+	file, err := w.create(name)
+	if err != nil {
+		return nil, err
+	}
+	sitemap := &sitemapFile{
+		name:   name,
+		file:   file,
+		writer: file,
+	}
+	if w.Gzip {
+		sitemap.gzip = gzip.NewWriter(file)
+		sitemap.writer = sitemap.gzip
+	}
+	return sitemap, nil
+}
+
+func (w *SitemapWriter) extension() string {
+	if w.Gzip {
+		return ".xml.gz"
+	}
+	return ".xml"
+}
+
+func (f *sitemapFile) close() error {
+	if f.gzip != nil {
+		if err := f.gzip.Close(); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}
+
+// marshalEntry encodes a sitemap entry as an XML element on its own line.
+func marshalEntry(name string, element xmlWriteLocation) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	encoder := xml.NewEncoder(buffer)
+	err := encoder.EncodeElement(element, xml.StartElement{Name: xml.Name{Local: name}})
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+package robots_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
+	"github.com/KillianMeersman/wander/request"
+)
+
+func sitemapResponse(t *testing.T, path string, res http.Response) *request.Response {
+	req, err := request.NewRequest(&url.URL{Scheme: "https", Host: "example.com", Path: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request.NewResponse(req, res)
+}
+
+// memoryFile is a sitemap file written to memory.
+type memoryFile struct {
+	bytes.Buffer
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+func memoryFiles() (map[string]*memoryFile, func(name string) (io.WriteCloser, error)) {
+	files := make(map[string]*memoryFile)
+	return files, func(name string) (io.WriteCloser, error) {
+		file := &memoryFile{}
+		files[name] = file
+		return file, nil
+	}
+}
+
+func TestSitemapWriter(t *testing.T) {
+	files, create := memoryFiles()
+	baseURL, _ := url.Parse("https://example.com/sitemaps/")
+	writer := robots.NewSitemapWriter(baseURL, create)
+	writer.Gzip = true
+
+	for i := 0; i <= robots.MaxSitemapLocations; i++ {
+		err := writer.AddURL(&url.URL{Scheme: "https", Host: "example.com", Path: fmt.Sprintf("/page/%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastModified := time.Date(2020, 6, 20, 3, 16, 10, 0, time.UTC)
+	// redirected pages are written under their final url
+	res := sitemapResponse(t, "/redirected", http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Last-Modified": []string{lastModified.Format(http.TimeFormat)}},
+		Request:    &http.Request{URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/modified"}},
+	})
+	if err := writer.AddResponse(res); err != nil {
+		t.Fatal(err)
+	}
+	// responses without a 2xx status code are not written
+	res = sitemapResponse(t, "/missing", http.Response{StatusCode: 404})
+	if err := writer.AddResponse(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("expected 2 sitemaps and an index, got %d files", len(files))
+	}
+	// closing again does not rewrite the index
+	written := files["sitemap_index.xml.gz"]
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files["sitemap_index.xml.gz"] != written {
+		t.Fatal("index written again when closing twice")
+	}
+
+	index, err := robots.NewSitemapFromReader(files["sitemap_index.xml.gz"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Index) != 2 || index.Index[1].Loc != "https://example.com/sitemaps/sitemap-2.xml.gz" {
+		t.Fatalf("invalid sitemap index %v", index.Index)
+	}
+
+	first, err := robots.NewSitemapFromReader(files["sitemap-1.xml.gz"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.URLSet) != robots.MaxSitemapLocations {
+		t.Fatalf("expected %d locations in the first sitemap, got %d", robots.MaxSitemapLocations, len(first.URLSet))
+	}
+
+	second, err := robots.NewSitemapFromReader(files["sitemap-2.xml.gz"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.URLSet) != 2 {
+		t.Fatalf("expected 2 locations in the second sitemap, got %d", len(second.URLSet))
+	}
+	if second.URLSet[1].Loc != "https://example.com/modified" || !second.URLSet[1].LastMod.Equal(lastModified) {
+		t.Fatalf("invalid location %v", second.URLSet[1])
+	}
+}
@@ -1,8 +1,13 @@
 package limits
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/KillianMeersman/wander/limits/robots"
 )
 
 // Throttle is used to limit the rate of requests.
@@ -15,28 +20,47 @@ type Throttle interface {
 	SetWaitTime(time.Duration)
 }
 
+// HostThrottle is a Throttle that keeps track of individual hosts.
+// It is implemented by ThrottleCollection and RedisThrottle.
+type HostThrottle interface {
+	Throttle
+	// SetHostWaitTime makes requests to a single host block for a duration.
+	SetHostWaitTime(host string, waitTime time.Duration)
+	// SetDomainThrottle sets the throttle for a domain, or the delay of an existing throttle for the same domain and path prefix.
+	SetDomainThrottle(throttle *DomainThrottle)
+}
+
 // ThrottleCollection combines a default and domain specific throttles.
+// When multiple domain throttles apply to a request, the most specific one is used, see DomainThrottle.
+// Safe for use by multiple goroutines.
 type ThrottleCollection struct {
 	defaultThrottle *DefaultThrottle
 	domainThrottles map[string]*DomainThrottle
+	// hostWaits holds the time until which requests to a host should wait, set by SetHostWaitTime
+	hostWaits map[string]time.Time
+	lock      *sync.RWMutex
 }
 
+// NewThrottleCollection instantiates a new throttle collection.
+// The default throttle may be nil, in which case requests to domains without a domain throttle are not throttled.
 func NewThrottleCollection(defaultThrottle *DefaultThrottle, domainThrottles ...*DomainThrottle) ThrottleCollection {
 	col := ThrottleCollection{
 		defaultThrottle: defaultThrottle,
 		domainThrottles: make(map[string]*DomainThrottle),
+		hostWaits:       make(map[string]time.Time),
+		lock:            &sync.RWMutex{},
 	}
 
 	for _, domainThrottle := range domainThrottles {
-		col.domainThrottles[domainThrottle.domain] = domainThrottle
+		col.domainThrottles[domainThrottle.key()] = domainThrottle
 	}
 
 	return col
 }
 
 func (t *ThrottleCollection) getThrottle(req *http.Request) Throttle {
-	throttle, ok := t.domainThrottles[req.URL.Host]
-	if ok {
+	throttle := t.getDomainThrottle(req)
+	if throttle != nil {
 		return throttle
 	}
 	if t.defaultThrottle != nil {
@@ -45,8 +69,17 @@ func (t *ThrottleCollection) getThrottle(req *http.Request) Throttle {
 	return nil
 }
 
-// Wait blocks until the most approprate timer has ticked over.
+// getDomainThrottle returns the most specific domain throttle applying to the request, nil if none apply.
+func (t *ThrottleCollection) getDomainThrottle(req *http.Request) *DomainThrottle {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return matchDomainThrottle(t.domainThrottles, req)
+}
+
+// Wait blocks until the most approprate throttle allows the request.
 func (t *ThrottleCollection) Wait(req *http.Request) {
+	t.waitForHost(req.URL.Host)
+
 	throttle := t.getThrottle(req)
 	if throttle != nil {
 		throttle.Wait(req)
@@ -60,6 +93,9 @@ func (t *ThrottleCollection) Applies(_ *http.Request) bool {
 
 // SetWaitTime make all throttles block for a duration.
 func (t *ThrottleCollection) SetWaitTime(waitTime time.Duration) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
 	if t.defaultThrottle != nil {
 		t.defaultThrottle.SetWaitTime(waitTime)
 	}
@@ -68,25 +104,68 @@ func (t *ThrottleCollection) SetWaitTime(waitTime time.Duration) {
 	}
 }
 
+// SetHostWaitTime makes requests to a single host block for a duration, regardless of which throttle applies to them.
+// An existing wait time for the host is only replaced if it ends sooner.
+func (t *ThrottleCollection) SetHostWaitTime(host string, waitTime time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	waitUntil := time.Now().Add(waitTime)
+	if waitUntil.After(t.hostWaits[host]) {
+		t.hostWaits[host] = waitUntil
+	}
+}
+
 // SetDomainThrottle sets a domain throttle.
-// Will overwrite existing domain throttle if it already exists.
+// An existing domain throttle with the same domain and path prefix takes over the delay of the new throttle,
+// its schedule is kept so setting the throttle again does not allow an early request.
 func (t *ThrottleCollection) SetDomainThrottle(throttle *DomainThrottle) {
-	t.domainThrottles[throttle.domain] = throttle
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if existing, ok := t.domainThrottles[throttle.key()]; ok {
+		existing.setInterval(throttle.interval)
+		return
+	}
+	t.domainThrottles[throttle.key()] = throttle
 }
 
-// DefaultThrottle will throttle all domains
+// waitForHost blocks until any wait time set for the host has passed.
+func (t *ThrottleCollection) waitForHost(host string) {
+	t.lock.RLock()
+	waitUntil, ok := t.hostWaits[host]
+	t.lock.RUnlock()
+	if !ok {
+		return
+	}
+
+	if time.Now().After(waitUntil) {
+		// the wait time has passed, remove it unless it was replaced in the meantime
+		t.lock.Lock()
+		if t.hostWaits[host] == waitUntil {
+			delete(t.hostWaits, host)
+		}
+		t.lock.Unlock()
+		return
+	}
+	time.Sleep(time.Until(waitUntil))
+}
+
+// DefaultThrottle will throttle all domains.
+// Requests are spaced at least one interval apart, each call to Wait reserves the next free slot.
+// Safe for use by multiple goroutines.
 type DefaultThrottle struct {
-	interval    time.Duration
-	ticker      *time.Ticker
-	waitChannel <-chan time.Time
+	interval time.Duration
+	lock     sync.Mutex
+	// next is the earliest time the next request may be made
+	next time.Time
+	// waitUntil is set by SetWaitTime, no requests are made before it
+	waitUntil time.Time
 }
 
-// NewDefaultThrottle will throttle all domains
+// NewDefaultThrottle will throttle all domains.
+// A delay of zero or less disables the interval, the throttle will then only block for wait times.
 func NewDefaultThrottle(delay time.Duration) *DefaultThrottle {
 	return &DefaultThrottle{
-		delay,
-		time.NewTicker(delay),
-		nil,
+		interval: delay,
 	}
 }
 
@@ -97,38 +176,136 @@ func (t *DefaultThrottle) Applies(_ *http.Request) bool {
 
 // Wait for the throttle
 func (t *DefaultThrottle) Wait(_ *http.Request) {
-	if t.waitChannel != nil {
-		<-t.waitChannel
-		t.waitChannel = nil
-	}
-	<-t.ticker.C
+	slot := t.reserve(time.Now())
+	time.Sleep(time.Until(slot))
 }
 
+// SetWaitTime makes the throttle block for a duration, an existing wait time is only replaced if it ends sooner.
 func (t *DefaultThrottle) SetWaitTime(waitTime time.Duration) {
-	t.waitChannel = time.After(waitTime)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	waitUntil := time.Now().Add(waitTime)
+	if waitUntil.After(t.waitUntil) {
+		t.waitUntil = waitUntil
+	}
+}
+
+func (t *DefaultThrottle) getInterval() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.interval
+}
+
+// setInterval changes the interval, the next free slot is not moved.
+func (t *DefaultThrottle) setInterval(interval time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.interval = interval
 }
 
-// DomainThrottle will throttle a specific domain.
+// reserve returns the next free slot and moves the following slot one interval further.
+func (t *DefaultThrottle) reserve(now time.Time) time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	slot := now
+	if t.next.After(slot) {
+		slot = t.next
+	}
+	if t.waitUntil.After(slot) {
+		slot = t.waitUntil
+	}
+	if t.interval > 0 {
+		t.next = slot.Add(t.interval)
+	}
+	return slot
+}
+
+// DomainThrottle will throttle a specific domain, optionally limited to paths starting with a prefix.
+// The domain may contain wildcards ('*'), it must match the whole host, see matchHost.
+//
+// When multiple domain throttles apply to a request, the one with the longest path prefix is used.
+// Throttles with equal path prefixes are ordered by the amount of non-wildcard characters in their domain, then by domain and path prefix.
 type DomainThrottle struct {
 	DefaultThrottle
-	domain string
+	domain     string
+	pathPrefix string
 }
 
 // NewDomainThrottle instantiates a new domain throttle.
 func NewDomainThrottle(domain string, delay time.Duration) *DomainThrottle {
-	throttle := NewDefaultThrottle(delay)
+	return NewPathThrottle(domain, "", delay)
+}
 
+// NewPathThrottle instantiates a new domain throttle that only applies to paths starting with pathPrefix.
+// Like the domain, the path prefix may contain wildcards.
+func NewPathThrottle(domain, pathPrefix string, delay time.Duration) *DomainThrottle {
 	return &DomainThrottle{
-		*throttle,
-		domain,
+		DefaultThrottle: DefaultThrottle{
+			interval: delay,
+		},
+		domain:     domain,
+		pathPrefix: pathPrefix,
 	}
 }
 
-// Applies returns true if the path matches the Throttle domain.
+// Applies returns true if the request host matches the throttle domain and the path starts with the throttle path prefix.
 func (t *DomainThrottle) Applies(req *http.Request) bool {
-	return t.domain == req.URL.Host
+	return matchHost(t.domain, req.URL.Host) && robots.MatchURLRule(t.pathPrefix, req.URL.Path)
+}
+
+// key identifies the throttle rule, throttles with the same key replace each other.
+func (t *DomainThrottle) key() string {
+	if t.pathPrefix == "" {
+		return t.domain
+	}
+	// hosts can't contain spaces
+	return t.domain + " " + t.pathPrefix
+}
+
+// moreSpecific returns true if the throttle takes precedence over other.
+func (t *DomainThrottle) moreSpecific(other *DomainThrottle) bool {
+	if length, otherLength := literalLength(t.pathPrefix), literalLength(other.pathPrefix); length != otherLength {
+		return length > otherLength
+	}
+	if length, otherLength := literalLength(t.domain), literalLength(other.domain); length != otherLength {
+		return length > otherLength
+	}
+	// a stable order, so the result doesn't depend on map iteration
+	return t.key() < other.key()
+}
+
+// matchDomainThrottle returns the most specific domain throttle applying to the request, nil if none apply.
+func matchDomainThrottle(throttles map[string]*DomainThrottle, req *http.Request) *DomainThrottle {
+	// fast path for exact host matches without path prefix
+	match := throttles[req.URL.Host]
+	for _, throttle := range throttles {
+		if (match == nil || throttle.moreSpecific(match)) && throttle.Applies(req) {
+			match = throttle
+		}
+	}
+	return match
+}
+
+// matchHost returns true if the host pattern matches the whole host, a pattern without port matches the host on any port.
+// The pattern may contain wildcards ('*'), e.a. "*.example.com" matches "www.example.com:8080" but not "www.example.com.evil.org".
+func matchHost(pattern, host string) bool {
+	pattern = strings.TrimSuffix(pattern, "$") + "$"
+	if robots.MatchURLRule(pattern, host) {
+		return true
+	}
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	if strings.Contains(hostname, ":") {
+		// ipv6 addresses are written in brackets
+		hostname = "[" + hostname + "]"
+	}
+	return robots.MatchURLRule(pattern, hostname)
 }
 
-func (t *DomainThrottle) SetWaitTime(waitTime time.Duration) {
-	t.waitChannel = time.After(waitTime)
+// literalLength returns the amount of characters in a pattern, excluding wildcards.
+func literalLength(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "$")
 }
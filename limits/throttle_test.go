@@ -0,0 +1,221 @@
+package limits_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits"
+)
+
+func throttleRequest(host string) *http.Request {
+	return &http.Request{URL: &url.URL{Scheme: "http", Host: host}}
+}
+
+func pathRequest(host, path string) *http.Request {
+	return &http.Request{URL: &url.URL{Scheme: "http", Host: host, Path: path}}
+}
+
+func TestDefaultThrottleInterval(t *testing.T) {
+	throttle := limits.NewDefaultThrottle(50 * time.Millisecond)
+	req := throttleRequest("localhost")
+
+	wg := sync.WaitGroup{}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.Wait(req)
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("5 requests made in %s, expected at least 200ms", elapsed)
+	}
+}
+
+func TestThrottleWaitTime(t *testing.T) {
+	throttles := limits.NewThrottleCollection(nil)
+	throttles.SetHostWaitTime("slow", 200*time.Millisecond)
+
+	start := time.Now()
+	throttles.Wait(throttleRequest("fast"))
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("wait time applied to other host, waited %s", elapsed)
+	}
+
+	throttles.Wait(throttleRequest("slow"))
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("wait time not applied, waited %s", elapsed)
+	}
+}
+
+func TestThrottleWaitTimeNotShortened(t *testing.T) {
+	throttles := limits.NewThrottleCollection(limits.NewDefaultThrottle(0))
+	throttles.SetHostWaitTime("slow", 200*time.Millisecond)
+	throttles.SetHostWaitTime("slow", time.Millisecond)
+	throttles.SetWaitTime(200 * time.Millisecond)
+	throttles.SetWaitTime(time.Millisecond)
+
+	start := time.Now()
+	throttles.Wait(throttleRequest("slow"))
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("host wait time shortened, waited %s", elapsed)
+	}
+
+	start = time.Now()
+	throttles.SetWaitTime(200 * time.Millisecond)
+	throttles.SetWaitTime(time.Millisecond)
+	throttles.Wait(throttleRequest("fast"))
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("wait time shortened, waited %s", elapsed)
+	}
+}
+
+func TestSetDomainThrottleKeepsSchedule(t *testing.T) {
+	throttles := limits.NewThrottleCollection(nil)
+	req := throttleRequest("example.com")
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		// the spider sets the robots.txt delay for every request added to the queue
+		throttles.SetDomainThrottle(limits.NewDomainThrottle("example.com", 50*time.Millisecond))
+		throttles.Wait(req)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("5 requests made in %s, expected at least 200ms", elapsed)
+	}
+
+	// a changed delay applies from the next free slot
+	throttles.SetDomainThrottle(limits.NewDomainThrottle("example.com", 0))
+	start = time.Now()
+	throttles.Wait(req)
+	throttles.Wait(req)
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond || elapsed > 150*time.Millisecond {
+		t.Fatalf("expected a single 50ms wait, waited %s", elapsed)
+	}
+}
+
+func TestDomainThrottleHostMatching(t *testing.T) {
+	cases := []struct {
+		domain, host string
+		applies      bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "example.com:8080", true},
+		{"example.com", "example.com.evil.org", false},
+		{"example.com", "www.example.com", false},
+		{"example.co", "example.com", false},
+		{"example.com$", "example.com", true},
+		{"*.example.com", "www.example.com:8080", true},
+		{"*.example.com", "www.example.com.evil.org", false},
+		{"example.*", "example.org", true},
+		{"localhost:8080", "localhost:8080", true},
+		{"localhost:8080", "localhost:8081", false},
+		{"[::1]", "[::1]:8080", true},
+	}
+	for _, c := range cases {
+		if applies := limits.NewDomainThrottle(c.domain, 0).Applies(throttleRequest(c.host)); applies != c.applies {
+			t.Errorf("%s applies to %s: expected %t, got %t", c.domain, c.host, c.applies, applies)
+		}
+	}
+}
+
+func TestThrottleRuleTiebreak(t *testing.T) {
+	// equally specific rules, the same one must be used every time
+	for i := 0; i < 10; i++ {
+		throttles := limits.NewThrottleCollection(
+			nil,
+			limits.NewDomainThrottle("a*.example.com", 0),
+			limits.NewDomainThrottle("*b.example.com", 50*time.Millisecond),
+			limits.NewPathThrottle("ab.example.com", "/**/items", 0),
+			limits.NewPathThrottle("ab.example.com", "/search/", 50*time.Millisecond),
+		)
+		start := time.Now()
+		throttles.Wait(throttleRequest("ab.example.com"))
+		throttles.Wait(throttleRequest("ab.example.com"))
+		if time.Since(start) < 25*time.Millisecond {
+			t.Fatal("equally specific domain throttles not ordered")
+		}
+
+		// wildcards don't count towards the path prefix length
+		start = time.Now()
+		throttles.Wait(pathRequest("ab.example.com", "/search/items"))
+		throttles.Wait(pathRequest("ab.example.com", "/search/items"))
+		if time.Since(start) < 25*time.Millisecond {
+			t.Fatal("path prefix wildcards counted as literal characters")
+		}
+	}
+}
+
+func TestThrottleRuleSpecificity(t *testing.T) {
+	throttles := limits.NewThrottleCollection(
+		nil,
+		limits.NewDomainThrottle("*.example.com", 200*time.Millisecond),
+		limits.NewPathThrottle("*.example.com", "/search", 0),
+		limits.NewDomainThrottle("static.example.com", 0),
+	)
+
+	// the path throttle is more specific than the domain throttle
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		throttles.Wait(pathRequest("www.example.com", "/search/items"))
+	}
+	// exact hosts are more specific than wildcards
+	for i := 0; i < 3; i++ {
+		throttles.Wait(pathRequest("static.example.com", "/image.png"))
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("wrong throttle applied, waited %s", elapsed)
+	}
+
+	// subdomains share the wildcard throttle
+	start = time.Now()
+	throttles.Wait(pathRequest("www.example.com", "/"))
+	throttles.Wait(pathRequest("shop.example.com", "/"))
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("wildcard throttle not applied, waited %s", elapsed)
+	}
+}
+
+// TestThrottleCollectionConcurrency hammers a throttle collection from many goroutines.
+// Run with -race to detect unsynchronized access.
+func TestThrottleCollectionConcurrency(t *testing.T) {
+	throttles := limits.NewThrottleCollection(limits.NewDefaultThrottle(time.Microsecond))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		host := fmt.Sprintf("host%d", i%5)
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				throttles.Wait(throttleRequest(host))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				throttles.SetDomainThrottle(limits.NewDomainThrottle(host, time.Microsecond))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				throttles.SetWaitTime(time.Microsecond)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				throttles.SetHostWaitTime(host, time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+}
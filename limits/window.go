@@ -0,0 +1,172 @@
+package limits
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeWindow is a recurring period of the day during which requests are allowed.
+// Start and End are offsets from midnight in the window's location, a window with an End before or equal to its Start spans midnight.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Weekdays on which the window starts, the window applies to all days if empty.
+	Weekdays []time.Weekday
+	// Location of the window, defaults to UTC if nil.
+	Location *time.Location
+}
+
+// NewTimeWindow instantiates a new time window.
+// The window applies to all days if no weekdays are given.
+func NewTimeWindow(start, end time.Duration, location *time.Location, weekdays ...time.Weekday) *TimeWindow {
+	return &TimeWindow{
+		Start:    start,
+		End:      end,
+		Weekdays: weekdays,
+		Location: location,
+	}
+}
+
+// Contains returns true if the time falls within the window.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	local := t.In(w.location())
+	// check the windows starting today and yesterday, as the latter may span midnight
+	for _, offset := range []int{-1, 0} {
+		day := local.AddDate(0, 0, offset)
+		if !w.onWeekday(day.Weekday()) {
+			continue
+		}
+		start, end := w.occurrence(day)
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the first time at or after t that falls within the window.
+// Returns t if the window has no weekdays it applies to.
+func (w *TimeWindow) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+
+	local := t.In(w.location())
+	for offset := 0; offset <= 7; offset++ {
+		day := local.AddDate(0, 0, offset)
+		if !w.onWeekday(day.Weekday()) {
+			continue
+		}
+		start, _ := w.occurrence(day)
+		if start.After(t) {
+			return start
+		}
+	}
+	return t
+}
+
+// occurrence returns the start and end of the window starting on the given day.
+func (w *TimeWindow) occurrence(day time.Time) (time.Time, time.Time) {
+	start := atOffset(day, w.Start)
+	end := atOffset(day, w.End)
+	if !end.After(start) {
+		end = atOffset(day.AddDate(0, 0, 1), w.End)
+	}
+	return start, end
+}
+
+func (w *TimeWindow) onWeekday(weekday time.Weekday) bool {
+	if len(w.Weekdays) < 1 {
+		return true
+	}
+	for _, day := range w.Weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TimeWindow) location() *time.Location {
+	if w.Location == nil {
+		return time.UTC
+	}
+	return w.Location
+}
+
+// atOffset returns the wall clock time offset from midnight on the given day, respecting daylight saving time.
+func atOffset(day time.Time, offset time.Duration) time.Time {
+	year, month, date := day.Date()
+	hour := int(offset / time.Hour)
+	minute := int(offset % time.Hour / time.Minute)
+	second := int(offset % time.Minute / time.Second)
+	return time.Date(year, month, date, hour, minute, second, int(offset%time.Second), day.Location())
+}
+
+// CrawlWindows holds the time windows during which hosts may be crawled.
+// Hosts may contain wildcards ('*') and must match the whole host, a host without port matches any port.
+// An exact host takes precedence over patterns, patterns with more non-wildcard characters take precedence over others.
+// Safe for use by multiple goroutines.
+type CrawlWindows struct {
+	hosts map[string][]*TimeWindow
+	lock  sync.RWMutex
+}
+
+// NewCrawlWindows instantiates a new crawl window collection.
+func NewCrawlWindows() *CrawlWindows {
+	return &CrawlWindows{
+		hosts: make(map[string][]*TimeWindow),
+	}
+}
+
+// SetHostWindows sets or replaces the time windows for a host.
+// Requests to the host are only allowed during any of the windows, passing no windows removes all restrictions.
+func (c *CrawlWindows) SetHostWindows(host string, windows ...*TimeWindow) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(windows) < 1 {
+		delete(c.hosts, host)
+		return
+	}
+	c.hosts[host] = windows
+}
+
+// NextAllowed returns the first time at or after now at which the request is allowed.
+func (c *CrawlWindows) NextAllowed(req *http.Request, now time.Time) time.Time {
+	windows := c.getWindows(req.URL.Host)
+	if len(windows) < 1 {
+		return now
+	}
+
+	next := windows[0].Next(now)
+	for _, window := range windows[1:] {
+		if windowNext := window.Next(now); windowNext.Before(next) {
+			next = windowNext
+		}
+	}
+	return next
+}
+
+func (c *CrawlWindows) getWindows(host string) []*TimeWindow {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if windows, ok := c.hosts[host]; ok {
+		return windows
+	}
+
+	var match []*TimeWindow
+	matchPattern, matchLength := "", -1
+	for pattern, windows := range c.hosts {
+		length := literalLength(pattern)
+		// equally specific patterns are ordered so the result doesn't depend on map iteration
+		if length < matchLength || (length == matchLength && pattern > matchPattern) || !matchHost(pattern, host) {
+			continue
+		}
+		match = windows
+		matchPattern, matchLength = pattern, length
+	}
+	return match
+}
@@ -0,0 +1,71 @@
+package limits_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/limits"
+)
+
+func TestTimeWindow(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// 22:00 - 06:00 in Tokyo on weekends
+	window := limits.NewTimeWindow(22*time.Hour, 6*time.Hour, tokyo, time.Saturday, time.Sunday)
+
+	// Saturday 23:00 JST
+	inside := time.Date(2020, time.June, 20, 23, 0, 0, 0, tokyo)
+	if !window.Contains(inside) {
+		t.Fatal("window should contain saturday 23:00")
+	}
+	// Monday 05:00 JST, the window started on sunday
+	if !window.Contains(time.Date(2020, time.June, 22, 5, 0, 0, 0, tokyo)) {
+		t.Fatal("window should contain monday 05:00")
+	}
+	if next := window.Next(inside); !next.Equal(inside) {
+		t.Fatalf("next should be now, got %s", next)
+	}
+
+	// Monday 12:00 JST, equal to 03:00 UTC
+	outside := time.Date(2020, time.June, 22, 3, 0, 0, 0, time.UTC)
+	if window.Contains(outside) {
+		t.Fatal("window should not contain monday 12:00")
+	}
+	expected := time.Date(2020, time.June, 27, 22, 0, 0, 0, tokyo)
+	if next := window.Next(outside); !next.Equal(expected) {
+		t.Fatalf("expected next window at %s, got %s", expected, next)
+	}
+
+	// 12:30 - 13:45 UTC
+	window = limits.NewTimeWindow(12*time.Hour+30*time.Minute, 13*time.Hour+45*time.Minute, time.UTC)
+	if window.Contains(time.Date(2020, time.June, 22, 13, 50, 0, 0, time.UTC)) {
+		t.Fatal("window should not contain 13:50")
+	}
+	expected = time.Date(2020, time.June, 22, 12, 30, 0, 0, time.UTC)
+	if next := window.Next(time.Date(2020, time.June, 22, 12, 0, 0, 0, time.UTC)); !next.Equal(expected) {
+		t.Fatalf("expected next window at %s, got %s", expected, next)
+	}
+}
+
+func TestCrawlWindows(t *testing.T) {
+	windows := limits.NewCrawlWindows()
+	windows.SetHostWindows("*.example.com", limits.NewTimeWindow(time.Hour, 2*time.Hour, nil))
+	windows.SetHostWindows("www.example.com", limits.NewTimeWindow(3*time.Hour, 4*time.Hour, nil))
+
+	now := time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"shop.example.com":          now.Add(time.Hour),
+		"www.example.com":           now.Add(3 * time.Hour),
+		"www.example.com:8080":      now.Add(3 * time.Hour),
+		"www.example.com.evil.org":  now,
+		"shop.example.com.evil.org": now,
+		"example.org":               now,
+	}
+	for host, expected := range cases {
+		req := &http.Request{URL: &url.URL{Scheme: "http", Host: host}}
+		if next := windows.NextAllowed(req, now); !next.Equal(expected) {
+			t.Fatalf("%s: expected %s, got %s", host, expected, next)
+		}
+	}
+}
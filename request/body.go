@@ -0,0 +1,89 @@
+package request
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// BodyTooLarge indicates a response body exceeded the maximum body size.
+// If Truncated is true the body was cut off at the limit, otherwise the response was aborted.
+type BodyTooLarge struct {
+	URL       url.URL
+	Limit     int64
+	Truncated bool
+}
+
+func (e BodyTooLarge) Error() string {
+	if e.Truncated {
+		return fmt.Sprintf("response from %s truncated to %d bytes", e.URL.String(), e.Limit)
+	}
+	return fmt.Sprintf("response from %s aborted, body exceeds %d bytes", e.URL.String(), e.Limit)
+}
+
+// limitedBody stops reading a body after a number of bytes.
+// Reads past the limit return BodyTooLarge, or io.EOF if the body is truncated.
+type limitedBody struct {
+	io.ReadCloser
+	res       *Response
+	limit     int64
+	remaining int64
+	truncate  bool
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.remaining <= 0 {
+		// the limit is only exceeded if there is more data
+		probe := make([]byte, 1)
+		_, err := io.ReadAtLeast(b.ReadCloser, probe, 1)
+		if err != nil {
+			return 0, err
+		}
+		if b.truncate {
+			b.res.Truncated = true
+			b.err = io.EOF
+		} else {
+			b.err = BodyTooLarge{URL: *b.res.Request.URL, Limit: b.limit}
+		}
+		return 0, b.err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// LimitBody limits the response body to limit bytes, the body is not limited if the limit is 0 or less.
+// Reading past the limit returns BodyTooLarge, unless truncate is true, the body then ends at the limit and Truncated is set.
+// Returns BodyTooLarge and closes the body right away if the Content-Length header exceeds the limit and truncate is false.
+func (r *Response) LimitBody(limit int64, truncate bool) error {
+	if limit <= 0 {
+		return nil
+	}
+	if r.ContentLength > limit && !truncate {
+		r.Body.Close()
+		return BodyTooLarge{URL: *r.Request.URL, Limit: limit}
+	}
+	r.Body = &limitedBody{
+		ReadCloser: r.Body,
+		res:        r,
+		limit:      limit,
+		remaining:  limit,
+		truncate:   truncate,
+	}
+	return nil
+}
+
+// StreamBody copies the body to a writer without buffering it in memory, e.a. to save large downloads to disk.
+// The body is closed afterwards, so the response can not be parsed anymore.
+func (r *Response) StreamBody(w io.Writer) (int64, error) {
+	defer r.Body.Close()
+	return io.Copy(w, r.Body)
+}
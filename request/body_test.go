@@ -0,0 +1,84 @@
+package request_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/request"
+)
+
+func newBodyResponse(t *testing.T, body string, contentLength int64) *request.Response {
+	u, _ := url.Parse("https://example.com/download")
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request.NewResponse(req, http.Response{
+		StatusCode:    200,
+		ContentLength: contentLength,
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+	})
+}
+
+func TestLimitBody(t *testing.T) {
+	// bodies at the limit are read completely
+	res := newBodyResponse(t, "0123456789", -1)
+	if err := res.LimitBody(10, false); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil || string(body) != "0123456789" {
+		t.Fatalf("expected full body, got %q, %v", body, err)
+	}
+
+	res = newBodyResponse(t, "0123456789", -1)
+	if err := res.LimitBody(5, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(res.Body)
+	if e, ok := err.(request.BodyTooLarge); !ok || e.Limit != 5 || e.Truncated {
+		t.Fatalf("expected BodyTooLarge, got %v", err)
+	}
+	// the error is returned by the parser as well
+	if _, err := res.Parse(); err == nil {
+		t.Fatal("expected parse error")
+	}
+
+	res = newBodyResponse(t, "0123456789", 10)
+	if err := res.LimitBody(5, false); err == nil {
+		t.Fatal("expected BodyTooLarge for Content-Length")
+	}
+}
+
+func TestLimitBodyTruncate(t *testing.T) {
+	res := newBodyResponse(t, "0123456789", 10)
+	if err := res.LimitBody(5, true); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "01234" || !res.Truncated {
+		t.Fatalf("expected truncated body, got %q", body)
+	}
+}
+
+func TestStreamBody(t *testing.T) {
+	res := newBodyResponse(t, "0123456789", -1)
+	if err := res.LimitBody(20, false); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	n, err := res.StreamBody(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || out.String() != "0123456789" {
+		t.Fatalf("unexpected stream output %q", out.String())
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"sync"
 )
 
-// Cache holds visited urls to prevent revisitation
+// Cache holds visited urls to prevent revisitation.
+// URLs are cached per session, so sessions can visit the same urls.
 type Cache interface {
 	AddRequest(req *Request) error
 	VisitedURL(req *Request) (bool, error)
@@ -29,7 +30,7 @@ func (c *LocalCache) AddRequest(req *Request) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.requests[req.URL.String()] = struct{}{}
+	c.requests[cacheKey(req)] = struct{}{}
 	return nil
 }
 
@@ -38,7 +39,7 @@ func (c *LocalCache) VisitedURL(req *Request) (bool, error) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	_, ok := c.requests[req.URL.String()]
+	_, ok := c.requests[cacheKey(req)]
 	return ok, nil
 }
 
@@ -46,3 +47,16 @@ func (c *LocalCache) Clear() error {
 	c.requests = make(map[string]struct{})
 	return nil
 }
+
+// cacheKey returns the key of a request in a cache, the url prefixed by the session if it has one.
+// Requests other than GET requests are also identified by their method and payload.
+func cacheKey(req *Request) string {
+	key := req.URL.String()
+	if payload := req.payloadKey(); payload != "" {
+		key = payload + " " + key
+	}
+	if req.Session != "" {
+		key = req.Session + " " + key
+	}
+	return key
+}
@@ -0,0 +1,112 @@
+package request
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// DetectCharset detects the character encoding of an HTML body, returning the encoding and its canonical name, e.a. "shift_jis".
+// The encoding is determined by a byte order mark, the charset parameter of the Content-Type header or a <meta charset> element, in that order.
+// A declared encoding is always used, even if the body happens to be valid UTF-8.
+// Bodies without an encoding declaration are assumed to be UTF-8 if they are valid UTF-8, ignoring a character cut off at the end, windows-1252 otherwise.
+func DetectCharset(body []byte, contentType string) (encoding.Encoding, string) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if certain || metaCharset(body) != "" {
+		return enc, name
+	}
+	// windows-1252 is also the fallback if nothing was declared, only the first 1024 bytes are checked for UTF-8
+	// a body truncated at the size limit may end in the middle of a character
+	if name == "windows-1252" && utf8.Valid(trimPartialRune(body)) {
+		return encoding.Nop, "utf-8"
+	}
+	return enc, name
+}
+
+// metaCharset returns the known charset declared by a <meta> element in the first 1024 bytes of an HTML body, empty if there is none.
+func metaCharset(body []byte) string {
+	if len(body) > 1024 {
+		body = body[:1024]
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, hasAttr := tokenizer.TagName()
+			if string(tag) != "meta" {
+				continue
+			}
+
+			var label, content string
+			var httpEquiv bool
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				switch string(key) {
+				case "charset":
+					label = string(val)
+				case "content":
+					content = string(val)
+				case "http-equiv":
+					httpEquiv = strings.EqualFold(string(val), "content-type")
+				}
+			}
+			if label == "" && httpEquiv {
+				label = contentCharset(content)
+			}
+			if enc, name := charset.Lookup(label); enc != nil {
+				return name
+			}
+		}
+	}
+}
+
+// contentCharset returns the charset parameter of a <meta http-equiv="Content-Type"> content attribute.
+func contentCharset(content string) string {
+	i := strings.Index(strings.ToLower(content), "charset")
+	if i < 0 {
+		return ""
+	}
+	value := strings.TrimLeft(content[i+len("charset"):], " \t")
+	if !strings.HasPrefix(value, "=") {
+		return ""
+	}
+	value = strings.Trim(strings.TrimLeft(value[1:], " \t"), `"'`)
+	if end := strings.IndexAny(value, "; \t\"'"); end > -1 {
+		value = value[:end]
+	}
+	return value
+}
+
+// trimPartialRune removes an incomplete UTF-8 sequence from the end of a body.
+func trimPartialRune(body []byte) []byte {
+	for i := len(body) - 1; i >= 0 && i > len(body)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(body[i]) {
+			if !utf8.FullRune(body[i:]) {
+				return body[:i]
+			}
+			break
+		}
+	}
+	return body
+}
+
+// decodeHTML detects the encoding of an HTML body and transcodes it to UTF-8, without byte order mark.
+// The body is returned as is if it could not be transcoded.
+func (r *Response) decodeHTML(body []byte) []byte {
+	enc, name := DetectCharset(body, r.Header.Get("Content-Type"))
+	r.Charset = name
+	if name != "utf-8" {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err == nil {
+			body = decoded
+		}
+	}
+	return bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+}
@@ -0,0 +1,81 @@
+package request_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/request"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encodeHTML(t *testing.T, enc encoding.Encoding, head, text string) string {
+	page := "<html><head>" + head + "</head><body><p>" + text + "</p></body></html>"
+	encoded, err := enc.NewEncoder().String(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestParseCharset(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		charset     string
+		text        string
+	}{
+		{"header", "text/html; charset=Shift_JIS", encodeHTML(t, japanese.ShiftJIS, "", "こんにちは"), "shift_jis", "こんにちは"},
+		{"meta", "text/html", encodeHTML(t, charmap.Windows1252, `<meta charset="windows-1252">`, "café"), "windows-1252", "café"},
+		{"meta valid utf-8", "text/html", encodeHTML(t, encoding.Nop, `<meta charset="iso-8859-1">`, "hello"), "windows-1252", "hello"},
+		{"http-equiv", "text/html", encodeHTML(t, simplifiedchinese.GBK, `<meta http-equiv="Content-Type" content="text/html; charset=gbk">`, "你好"), "gbk", "你好"},
+		{"bom", "text/html", encodeHTML(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "", "hällo"), "utf-16le", "hällo"},
+		{"undeclared", "text/html", "<html><body><p>" + strings.Repeat(" ", 2000) + "naïve</p></body></html>", "utf-8", strings.Repeat(" ", 2000) + "naïve"},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse("https://example.com/" + c.name)
+		req, err := request.NewRequest(u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := make(http.Header)
+		header.Set("Content-Type", c.contentType)
+		res := request.NewResponse(req, http.Response{
+			StatusCode: 200,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader(c.body)),
+		})
+
+		doc, err := res.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Charset != c.charset {
+			t.Errorf("%s: expected charset %s, got %s", c.name, c.charset, res.Charset)
+		}
+		if text := doc.Find("p").Text(); text != c.text {
+			t.Errorf("%s: expected text %q, got %q", c.name, c.text, text)
+		}
+	}
+}
+
+func TestDetectCharsetTruncated(t *testing.T) {
+	body := "<html><body><p>" + strings.Repeat(" ", 2000) + "héllo €"
+	// the body is cut off at the size limit in the middle of the euro sign
+	truncated := []byte(body[:len(body)-1])
+	if _, name := request.DetectCharset(truncated, "text/html"); name != "utf-8" {
+		t.Fatalf("expected utf-8 for a truncated body, got %s", name)
+	}
+
+	invalid := append([]byte(strings.Repeat(" ", 2000)+"caf"), 0xe9, ' ', 'a')
+	if _, name := request.DetectCharset(invalid, "text/html"); name != "windows-1252" {
+		t.Fatalf("expected windows-1252 for invalid UTF-8, got %s", name)
+	}
+}
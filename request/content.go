@@ -0,0 +1,123 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentKind is the kind of content of a response, used to decide how it is parsed.
+type ContentKind int
+
+// Content kinds, content that is neither HTML, JSON, XML nor text is binary.
+const (
+	ContentBinary ContentKind = iota
+	ContentHTML
+	ContentJSON
+	ContentXML
+	ContentText
+)
+
+func (k ContentKind) String() string {
+	switch k {
+	case ContentHTML:
+		return "html"
+	case ContentJSON:
+		return "json"
+	case ContentXML:
+		return "xml"
+	case ContentText:
+		return "text"
+	default:
+		return "binary"
+	}
+}
+
+// sniffLength is the amount of bytes used to sniff the content type, see http.DetectContentType.
+const sniffLength = 512
+
+// ContentType returns the media type of the response, without parameters.
+// The Content-Type header is used if it is set to a specific type, otherwise the type is sniffed from the start of the body using http.DetectContentType.
+// JSON bodies are detected as well, unlike with http.DetectContentType. The body can still be read completely after sniffing.
+func (r *Response) ContentType() string {
+	if r.contentType != "" {
+		return r.contentType
+	}
+
+	mediaType := ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "" || mediaType == "application/octet-stream" || mediaType == "text/plain" {
+		if sniffed := r.sniffContentType(); sniffed != "" {
+			mediaType = sniffed
+		}
+	}
+	r.contentType = mediaType
+	return mediaType
+}
+
+// ContentKind returns the kind of content of the response, based on ContentType.
+func (r *Response) ContentKind() ContentKind {
+	return MediaTypeKind(r.ContentType())
+}
+
+// MediaTypeKind returns the kind of content of a media type.
+// XHTML is treated as HTML, types with a +json or +xml suffix such as application/ld+json and application/rss+xml as JSON and XML.
+func MediaTypeKind(mediaType string) ContentKind {
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return ContentHTML
+	case mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"):
+		return ContentJSON
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return ContentXML
+	case strings.HasPrefix(mediaType, "text/"):
+		return ContentText
+	default:
+		return ContentBinary
+	}
+}
+
+// sniffContentType detects the media type from the start of the body.
+// The sniffed bytes are put back in front of the body. Returns an empty string if the body is empty.
+func (r *Response) sniffContentType() string {
+	var start []byte
+	if r.body != nil {
+		start = r.body
+	} else {
+		start = make([]byte, sniffLength)
+		n, _ := io.ReadFull(r.Body, start)
+		start = start[:n]
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(start), r.Body), r.Body}
+	}
+	if len(start) > sniffLength {
+		start = start[:sniffLength]
+	}
+	if len(start) == 0 {
+		return ""
+	}
+
+	mediaType := ParseMediaType(http.DetectContentType(start))
+	if mediaType == "text/plain" {
+		trimmed := bytes.TrimLeft(start, " \t\r\n\ufeff")
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return "application/json"
+		}
+	}
+	return mediaType
+}
+
+// readCloser combines a reader with the closer of another reader.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ParseMediaType returns the lowercase media type of a Content-Type header without parameters, e.a. "text/html".
+func ParseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mediaType
+}
@@ -0,0 +1,116 @@
+package request_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/request"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+)
+
+func newContentResponse(t *testing.T, contentType, body string) *request.Response {
+	u, _ := url.Parse("https://example.com/content")
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return request.NewResponse(req, http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	})
+}
+
+func TestContentType(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		mediaType   string
+		kind        request.ContentKind
+	}{
+		{"text/html; charset=utf-8", "", "text/html", request.ContentHTML},
+		{"application/ld+json", "{}", "application/ld+json", request.ContentJSON},
+		{"application/rss+xml", "<rss/>", "application/rss+xml", request.ContentXML},
+		{"image/png", "", "image/png", request.ContentBinary},
+		{"", "<!DOCTYPE html><html></html>", "text/html", request.ContentHTML},
+		{"", "  [1, 2, 3]", "application/json", request.ContentJSON},
+		{"text/plain", `{"json": true}`, "application/json", request.ContentJSON},
+		{"application/octet-stream", "%PDF-1.5", "application/pdf", request.ContentBinary},
+		{"", `<?xml version="1.0"?><feed/>`, "text/xml", request.ContentXML},
+		{"text/plain", "plain text", "text/plain", request.ContentText},
+	}
+	for _, c := range cases {
+		res := newContentResponse(t, c.contentType, c.body)
+		if mediaType := res.ContentType(); mediaType != c.mediaType {
+			t.Errorf("expected %s for %q, got %s", c.mediaType, c.body, mediaType)
+		}
+		if kind := res.ContentKind(); kind != c.kind {
+			t.Errorf("expected %s for %q, got %s", c.kind, c.body, kind)
+		}
+		// sniffing doesn't consume the body
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != c.body {
+			t.Errorf("expected body %q after sniffing, got %q", c.body, body)
+		}
+	}
+}
+
+func TestResponseJSON(t *testing.T) {
+	res := newContentResponse(t, "application/json", `{"items": [{"name": "a", "price": 5}, {"name": "b", "price": 15}]}`)
+	doc, err := res.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := jsonquery.Find(doc, "//items/*[price>10]/name")
+	if len(nodes) != 1 || nodes[0].InnerText() != "b" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if body, _ := ioutil.ReadAll(res.Body); len(body) == 0 {
+		t.Fatal("body not readable after parsing")
+	}
+}
+
+func TestResponseXML(t *testing.T) {
+	res := newContentResponse(t, "application/rss+xml", `<rss><channel><item><title>first</title></item><item><title>second</title></item></channel></rss>`)
+	doc, err := res.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := xmlquery.Find(doc, "//item/title")
+	if len(nodes) != 2 || nodes[1].InnerText() != "second" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+}
+
+func TestResponseXPath(t *testing.T) {
+	res := newContentResponse(t, "text/html", `<html><body>
+	<h2>Price</h2><p>10</p>
+	<h2>Stock</h2><p>3</p>
+	</body></html>`)
+	nodes, err := res.XPath(`//h2[text()="Stock"]/following-sibling::p[1]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].FirstChild.Data != "3" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	// the goquery document is shared
+	if res.Document == nil || res.Document.Find("p").Length() != 2 {
+		t.Fatal("document not parsed")
+	}
+
+	if _, err := res.XPath("//h2["); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+}
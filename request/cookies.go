@@ -0,0 +1,205 @@
+package request
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJars holds a cookie jar for each session.
+// Requests use the jar of their Session, requests without a session share the default ("") session.
+type CookieJars interface {
+	// Jar returns the cookie jar for a session, creating it if needed.
+	Jar(session string) (http.CookieJar, error)
+	// Clear removes all cookies of all sessions.
+	Clear() error
+}
+
+// cookieRecord is a cookie set for a URL, cookie jars are persisted by replaying these.
+type cookieRecord struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// newCookieRecord records a cookie, converting Max-Age to an expiry time so the cookie expires at the same time when it is replayed.
+func newCookieRecord(u *url.URL, cookie *http.Cookie, now time.Time) cookieRecord {
+	recorded := *cookie
+	if recorded.MaxAge > 0 {
+		recorded.Expires = now.Add(time.Duration(recorded.MaxAge) * time.Second)
+		recorded.MaxAge = 0
+	}
+	return cookieRecord{
+		URL:    (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+		Cookie: &recorded,
+	}
+}
+
+// key identifies the cookie a record sets, later records for the same cookie replace earlier ones.
+// Cookies are identified by their name, domain and path, defaulting to the host and directory of the URL that set them.
+func (r cookieRecord) key() string {
+	path := r.Cookie.Path
+	if u, err := url.Parse(r.URL); err == nil {
+		if path == "" || path[0] != '/' {
+			path = u.Path
+			if i := strings.LastIndexByte(path, '/'); i > 0 {
+				path = path[:i]
+			} else {
+				path = "/"
+			}
+		}
+	}
+	return r.domain() + "\x00" + path + "\x00" + r.Cookie.Name
+}
+
+// domain returns the lowercase domain of the cookie without leading dot, defaulting to the host of the URL that set it.
+func (r cookieRecord) domain() string {
+	domain := r.Cookie.Domain
+	if domain == "" {
+		if u, err := url.Parse(r.URL); err == nil {
+			domain = u.Hostname()
+		}
+	}
+	return strings.TrimPrefix(strings.ToLower(domain), ".")
+}
+
+// newJar returns an empty cookie jar using the public suffix list.
+func newJar() *cookiejar.Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+// replay returns a cookie jar holding the recorded cookies, expired cookies are dropped by the jar.
+func replay(records map[string]cookieRecord) *cookiejar.Jar {
+	jar := newJar()
+	for _, record := range records {
+		u, err := url.Parse(record.URL)
+		if err != nil {
+			continue
+		}
+		jar.SetCookies(u, []*http.Cookie{record.Cookie})
+	}
+	return jar
+}
+
+// LocalCookieJars holds cookie jars in memory. Safe for use by multiple goroutines.
+// The jars can be saved and loaded to persist cookies between crawls.
+type LocalCookieJars struct {
+	jars map[string]*recordingJar
+	lock sync.Mutex
+}
+
+// NewCookieJars instantiates a new in-memory cookie jar collection.
+func NewCookieJars() *LocalCookieJars {
+	return &LocalCookieJars{
+		jars: make(map[string]*recordingJar),
+	}
+}
+
+// NewCookieJarsFromReader loads cookie jars saved with LocalCookieJars.Save.
+func NewCookieJarsFromReader(in io.Reader) (*LocalCookieJars, error) {
+	saved := make(map[string][]cookieRecord)
+	err := json.NewDecoder(in).Decode(&saved)
+	if err != nil {
+		return nil, err
+	}
+
+	jars := NewCookieJars()
+	for session, records := range saved {
+		jar := newRecordingJar()
+		for _, record := range records {
+			jar.records[record.key()] = record
+		}
+		jar.jar = replay(jar.records)
+		jars.jars[session] = jar
+	}
+	return jars, nil
+}
+
+// Jar returns the cookie jar for a session, creating it if needed.
+func (c *LocalCookieJars) Jar(session string) (http.CookieJar, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	jar, ok := c.jars[session]
+	if !ok {
+		jar = newRecordingJar()
+		c.jars[session] = jar
+	}
+	return jar, nil
+}
+
+// Save writes the cookies of all sessions, they can be loaded using NewCookieJarsFromReader.
+func (c *LocalCookieJars) Save(out io.Writer) error {
+	c.lock.Lock()
+	saved := make(map[string][]cookieRecord, len(c.jars))
+	for session, jar := range c.jars {
+		saved[session] = jar.recorded()
+	}
+	c.lock.Unlock()
+
+	return json.NewEncoder(out).Encode(saved)
+}
+
+// Clear removes all cookies of all sessions.
+func (c *LocalCookieJars) Clear() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.jars = make(map[string]*recordingJar)
+	return nil
+}
+
+// recordingJar is a cookie jar recording the cookies set, so it can be saved.
+type recordingJar struct {
+	jar     *cookiejar.Jar
+	records map[string]cookieRecord
+	lock    sync.Mutex
+}
+
+func newRecordingJar() *recordingJar {
+	return &recordingJar{
+		jar:     newJar(),
+		records: make(map[string]cookieRecord),
+	}
+}
+
+// SetCookies implements the http.CookieJar interface.
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		record := newCookieRecord(u, cookie, now)
+		j.records[record.key()] = record
+	}
+	j.jar.SetCookies(u, cookies)
+}
+
+// Cookies implements the http.CookieJar interface.
+func (j *recordingJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// recorded returns the records of all cookies that have not expired.
+func (j *recordingJar) recorded() []cookieRecord {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	records := make([]cookieRecord, 0, len(j.records))
+	for key, record := range j.records {
+		if record.Cookie.MaxAge < 0 || (!record.Cookie.Expires.IsZero() && record.Cookie.Expires.Before(now)) {
+			delete(j.records, key)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
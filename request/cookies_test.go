@@ -0,0 +1,140 @@
+package request_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/request"
+	"github.com/go-redis/redis/v7"
+)
+
+func testCookieJars(t *testing.T, jars request.CookieJars) {
+	u, _ := url.Parse("https://example.com/account/login")
+	home, _ := url.Parse("https://example.com/")
+
+	jar, err := jars.Jar("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "first", Path: "/"},
+		{Name: "scoped", Value: "account"},
+	})
+	// later cookies replace earlier ones, regardless of the url that set them
+	jar.SetCookies(home, []*http.Cookie{{Name: "session", Value: "alice", Path: "/", MaxAge: 3600}})
+
+	cookies := jar.Cookies(home)
+	if len(cookies) != 1 || cookies[0].Value != "alice" {
+		t.Fatalf("expected session cookie, got %v", cookies)
+	}
+	if cookies := jar.Cookies(u); len(cookies) != 2 {
+		t.Fatalf("expected 2 cookies for %s, got %v", u, cookies)
+	}
+
+	other, err := jars.Jar("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := other.Cookies(home); len(cookies) != 0 {
+		t.Fatalf("cookies shared between sessions: %v", cookies)
+	}
+
+	err = jars.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, err = jars.Jar("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := jar.Cookies(home); len(cookies) != 0 {
+		t.Fatalf("cookies left after clearing: %v", cookies)
+	}
+}
+
+func TestLocalCookieJars(t *testing.T) {
+	testCookieJars(t, request.NewCookieJars())
+}
+
+func TestLocalCookieJarsSave(t *testing.T) {
+	jars := request.NewCookieJars()
+	u, _ := url.Parse("https://example.com/")
+	jar, _ := jars.Jar("alice")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "alice", MaxAge: 3600},
+		{Name: "deleted", Value: "", MaxAge: -1},
+	})
+
+	saved := &bytes.Buffer{}
+	err := jars.Save(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := request.NewCookieJarsFromReader(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, _ = loaded.Jar("alice")
+	cookies := jar.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "alice" {
+		t.Fatalf("expected loaded session cookie, got %v", cookies)
+	}
+}
+
+func TestRedisCookieJars(t *testing.T) {
+	jars, err := request.NewRedisCookieJars("localhost", 6379, "", "wander_cookies", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCookieJars(t, jars)
+}
+
+func TestRedisCookieJarsExpiry(t *testing.T) {
+	jars, err := request.NewRedisCookieJars("localhost", 6379, "", "wander_cookies_expiry", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jars.Clear()
+	jars.SessionCookieTTL = time.Hour
+
+	jar, _ := jars.Jar("alice")
+	u, _ := url.Parse("https://www.example.com/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "alice"},
+		{Name: "shared", Value: "alice", Domain: "example.com", MaxAge: 7200},
+	})
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "other.org", Path: "/"}, []*http.Cookie{{Name: "other", Value: "alice"}})
+
+	if cookies := jar.Cookies(u); len(cookies) != 2 {
+		t.Fatalf("expected 2 cookies, got %v", cookies)
+	}
+	if cookies := jar.Cookies(&url.URL{Scheme: "https", Host: "shop.example.com", Path: "/"}); len(cookies) != 1 || cookies[0].Name != "shared" {
+		t.Fatalf("expected the shared cookie, got %v", cookies)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+	cases := map[string]time.Duration{
+		"wander_cookies_expiry:alice:www.example.com": time.Hour,
+		"wander_cookies_expiry:alice:example.com":     2 * time.Hour,
+	}
+	for key, expected := range cases {
+		ttl, err := client.PTTL(key).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ttl <= expected-time.Minute || ttl > expected+time.Second {
+			t.Fatalf("expected %s to expire in %s, got %s", key, expected, ttl)
+		}
+	}
+
+	// deleting a cookie removes it from Redis
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	if n, err := client.HLen("wander_cookies_expiry:alice:www.example.com").Result(); err != nil || n != 0 {
+		t.Fatalf("deleted cookie left in Redis: %d %v", n, err)
+	}
+}
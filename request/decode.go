@@ -0,0 +1,151 @@
+package request
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding is the Accept-Encoding header sent by the spider, listing the content encodings DecodeBody supports.
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// maxZstdWindow limits the memory used to decode zstd bodies.
+const maxZstdWindow = 8 << 20
+
+// UnsupportedEncoding indicates a response body was encoded with an unsupported content encoding.
+type UnsupportedEncoding struct {
+	URL      url.URL
+	Encoding string
+}
+
+func (e UnsupportedEncoding) Error() string {
+	return fmt.Sprintf("response from %s has unsupported content encoding %s", e.URL.String(), e.Encoding)
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	io.Reader
+	count *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	*r.count += int64(n)
+	return n, err
+}
+
+// decodedBody is a decoded body, closing it closes the decoders and the original body.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b decodedBody) Close() error {
+	var err error
+	for _, closer := range b.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// DecodeBody decodes the body according to the Content-Encoding header, supporting gzip, deflate, br and zstd.
+// Bodies are decoded while they are read, WireSize and DecodedSize hold the amount of bytes read before and after decoding.
+// The Content-Encoding header is removed, the Content-Length header is removed and Uncompressed set if the body was encoded.
+// Returns UnsupportedEncoding if the body uses any other encoding, the body is left as is in that case.
+func (r *Response) DecodeBody() error {
+	encodings := make([]string, 0)
+	for _, encoding := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	for _, encoding := range encodings {
+		if _, ok := decoders[encoding]; !ok {
+			return UnsupportedEncoding{URL: *r.Request.URL, Encoding: encoding}
+		}
+	}
+
+	closers := []io.Closer{r.Body}
+	var reader io.Reader = countingReader{r.Body, &r.WireSize}
+	// encodings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder := &lazyReader{open: decoders[encodings[i]], in: reader}
+		closers = append([]io.Closer{decoder}, closers...)
+		reader = decoder
+	}
+
+	r.Body = decodedBody{countingReader{reader, &r.DecodedSize}, closers}
+	r.Header.Del("Content-Encoding")
+	if len(encodings) > 0 {
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		r.Uncompressed = true
+	}
+	return nil
+}
+
+// decoders open a decoder for each supported content encoding.
+var decoders = map[string]func(io.Reader) (io.Reader, error){
+	"gzip": func(in io.Reader) (io.Reader, error) {
+		return gzip.NewReader(in)
+	},
+	"x-gzip": func(in io.Reader) (io.Reader, error) {
+		return gzip.NewReader(in)
+	},
+	// deflate bodies are zlib streams according to the spec, but raw deflate streams on some servers
+	"deflate": func(in io.Reader) (io.Reader, error) {
+		buffered := bufio.NewReader(in)
+		header, err := buffered.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	},
+	"br": func(in io.Reader) (io.Reader, error) {
+		return brotli.NewReader(in), nil
+	},
+	"zstd": func(in io.Reader) (io.Reader, error) {
+		decoder, err := zstd.NewReader(in, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// lazyReader opens a decoder on the first read, so a malformed body is reported when it is read rather than when the response is received.
+type lazyReader struct {
+	open   func(io.Reader) (io.Reader, error)
+	in     io.Reader
+	reader io.Reader
+	err    error
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		r.reader, r.err = r.open(r.in)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+// Close closes the decoder if it was opened.
+func (r *lazyReader) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package request_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/request"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+var encoders = map[string]func(io.Writer) io.WriteCloser{
+	"gzip": func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	},
+	"deflate": func(w io.Writer) io.WriteCloser {
+		return zlib.NewWriter(w)
+	},
+	"br": func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriter(w)
+	},
+	"zstd": func(w io.Writer) io.WriteCloser {
+		encoder, _ := zstd.NewWriter(w)
+		return encoder
+	},
+}
+
+func encode(t *testing.T, body []byte, encodings ...string) []byte {
+	for _, encoding := range encodings {
+		out := &bytes.Buffer{}
+		writer := encoders[encoding](out)
+		if _, err := writer.Write(body); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		body = out.Bytes()
+	}
+	return body
+}
+
+func newEncodedResponse(t *testing.T, contentEncoding string, body []byte) *request.Response {
+	u, _ := url.Parse("https://example.com/encoded")
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Encoding", contentEncoding)
+	return request.NewResponse(req, http.Response{
+		StatusCode:    200,
+		Header:        header,
+		ContentLength: int64(len(body)),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	})
+}
+
+func TestDecodeBody(t *testing.T) {
+	plain := []byte(strings.Repeat("wander ", 1000))
+	rawDeflate := &bytes.Buffer{}
+	writer, _ := flate.NewWriter(rawDeflate, flate.DefaultCompression)
+	writer.Write(plain)
+	writer.Close()
+
+	cases := []struct {
+		contentEncoding string
+		body            []byte
+	}{
+		{"", plain},
+		{"identity", plain},
+		{"gzip", encode(t, plain, "gzip")},
+		{"deflate", encode(t, plain, "deflate")},
+		{"deflate", rawDeflate.Bytes()},
+		{"br", encode(t, plain, "br")},
+		{"zstd", encode(t, plain, "zstd")},
+		{"gzip, BR", encode(t, plain, "gzip", "br")},
+	}
+	for _, c := range cases {
+		res := newEncodedResponse(t, c.contentEncoding, c.body)
+		if err := res.DecodeBody(); err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", c.contentEncoding, err)
+		}
+		res.Body.Close()
+		if !bytes.Equal(body, plain) {
+			t.Fatalf("%s: body not decoded", c.contentEncoding)
+		}
+		if res.WireSize != int64(len(c.body)) || res.DecodedSize != int64(len(plain)) {
+			t.Fatalf("%s: expected sizes %d and %d, got %d and %d", c.contentEncoding, len(c.body), len(plain), res.WireSize, res.DecodedSize)
+		}
+		if res.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s: Content-Encoding header not removed", c.contentEncoding)
+		}
+	}
+
+	res := newEncodedResponse(t, "compress", plain)
+	if _, ok := res.DecodeBody().(request.UnsupportedEncoding); !ok {
+		t.Fatal("expected UnsupportedEncoding")
+	}
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	// a small body decoding to 100MiB
+	bomb := encode(t, make([]byte, 100<<20), "gzip")
+	res := newEncodedResponse(t, "gzip", bomb)
+	if err := res.DecodeBody(); err != nil {
+		t.Fatal(err)
+	}
+	if err := res.LimitBody(1<<20, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ioutil.ReadAll(res.Body)
+	if _, ok := err.(request.BodyTooLarge); !ok {
+		t.Fatalf("expected BodyTooLarge, got %v", err)
+	}
+	if res.DecodedSize > 1<<20+32<<10 {
+		t.Fatalf("decoded %d bytes past the limit", res.DecodedSize)
+	}
+}
@@ -0,0 +1,199 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Form encoding types.
+const (
+	FormURLEncoded = "application/x-www-form-urlencoded"
+	FormMultipart  = "multipart/form-data"
+)
+
+// FormNotFound indicates no form matched a selector.
+type FormNotFound struct {
+	Selector string
+}
+
+func (e FormNotFound) Error() string {
+	return fmt.Sprintf("no form matching %s found", e.Selector)
+}
+
+// formFile is a file uploaded in a multipart form.
+type formFile struct {
+	filename string
+	content  []byte
+}
+
+// Form is an HTML form that can be filled in and submitted as a request.
+type Form struct {
+	// Action is the absolute url the form is submitted to.
+	Action *url.URL
+	// Method is the uppercase http method used to submit the form, GET or POST.
+	Method string
+	// EncType is the encoding of POST forms, FormURLEncoded or FormMultipart.
+	EncType string
+	// Values holds the field values, pre-filled with the values in the document.
+	Values url.Values
+	files  map[string]formFile
+	parent *Request
+}
+
+// Form finds the first form matching the selector and reads its fields.
+// Fields are pre-filled with their values in the document, only checked checkboxes and radio buttons and selected options are included.
+// Returns FormNotFound if no form matches the selector.
+func (r *Response) Form(selector string) (*Form, error) {
+	doc, err := r.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	selection := doc.Find(selector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return goquery.NodeName(s) == "form"
+	}).First()
+	if selection.Length() < 1 {
+		return nil, FormNotFound{Selector: selector}
+	}
+	return NewForm(selection, r)
+}
+
+// NewForm reads the fields of a form selection, the response is used to resolve the form's action and becomes the parent of the submitted request.
+func NewForm(selection *goquery.Selection, res *Response) (*Form, error) {
+	action, err := res.FinalURL().Parse(selection.AttrOr("action", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	form := &Form{
+		Action:  action,
+		Method:  strings.ToUpper(strings.TrimSpace(selection.AttrOr("method", "GET"))),
+		EncType: strings.ToLower(strings.TrimSpace(selection.AttrOr("enctype", FormURLEncoded))),
+		Values:  make(url.Values),
+		files:   make(map[string]formFile),
+		parent:  res.Request,
+	}
+	if form.Method != "POST" {
+		form.Method = "GET"
+	}
+	if form.EncType != FormMultipart {
+		form.EncType = FormURLEncoded
+	}
+
+	selection.Find("input[name], textarea[name], select[name]").Each(func(_ int, field *goquery.Selection) {
+		name := field.AttrOr("name", "")
+		if _, disabled := field.Attr("disabled"); disabled {
+			return
+		}
+
+		switch goquery.NodeName(field) {
+		case "textarea":
+			form.Values.Add(name, field.Text())
+		case "select":
+			options := field.Find("option[selected]")
+			if options.Length() < 1 {
+				if _, multiple := field.Attr("multiple"); multiple {
+					return
+				}
+				options = field.Find("option").First()
+			}
+			options.Each(func(_ int, option *goquery.Selection) {
+				form.Values.Add(name, option.AttrOr("value", option.Text()))
+			})
+		default:
+			switch strings.ToLower(field.AttrOr("type", "text")) {
+			case "checkbox", "radio":
+				if _, checked := field.Attr("checked"); checked {
+					form.Values.Add(name, field.AttrOr("value", "on"))
+				}
+			case "submit", "button", "image", "reset", "file":
+			default:
+				form.Values.Add(name, field.AttrOr("value", ""))
+			}
+		}
+	})
+	return form, nil
+}
+
+// Set sets a field to a value, replacing any existing values.
+func (f *Form) Set(name, value string) {
+	f.Values.Set(name, value)
+}
+
+// Add adds a value to a field.
+func (f *Form) Add(name, value string) {
+	f.Values.Add(name, value)
+}
+
+// SetFile sets a file field, the form is submitted as a multipart form.
+func (f *Form) SetFile(name, filename string, content []byte) {
+	f.files[name] = formFile{filename, content}
+	f.Method = "POST"
+	f.EncType = FormMultipart
+}
+
+// Request returns a request submitting the form.
+// GET forms are submitted in the url query, POST forms in the request body using the form's encoding.
+func (f *Form) Request() (*Request, error) {
+	action := *f.Action
+	req, err := NewRequest(&action, f.parent)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = make(http.Header)
+
+	if f.Method == "GET" {
+		req.URL.RawQuery = f.Values.Encode()
+		return req, nil
+	}
+
+	req.Method = "POST"
+	if f.EncType != FormMultipart {
+		req.Payload = []byte(f.Values.Encode())
+		req.Header.Set("Content-Type", FormURLEncoded)
+		return req, nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	names := make([]string, 0, len(f.Values))
+	for name := range f.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range f.Values[name] {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	names = names[:0]
+	for name := range f.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		part, err := writer.CreateFormFile(name, f.files[name].filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(f.files[name].content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req.Payload = body.Bytes()
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
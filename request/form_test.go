@@ -0,0 +1,159 @@
+package request_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KillianMeersman/wander/request"
+)
+
+const loginPage = `<html><body>
+<form id="search" action="/search"><input name="q" value="wander"></form>
+<form id="login" action="/login" method="post">
+	<input type="hidden" name="token" value="abc">
+	<input type="text" name="username">
+	<input type="password" name="password">
+	<input type="checkbox" name="remember" checked>
+	<input type="checkbox" name="newsletter" value="yes">
+	<input type="text" name="disabled" value="x" disabled>
+	<select name="lang"><option value="en">English</option><option value="nl" selected>Dutch</option></select>
+	<textarea name="note">hello</textarea>
+	<input type="submit" name="submit" value="Log in">
+</form>
+</body></html>`
+
+func newFormResponse(t *testing.T, page string) *request.Response {
+	u, _ := url.Parse("https://example.com/account/")
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Session = "alice"
+	return request.NewResponse(req, http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(page)),
+	})
+}
+
+func TestForm(t *testing.T) {
+	res := newFormResponse(t, loginPage)
+	form, err := res.Form("#login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Method != "POST" || form.EncType != request.FormURLEncoded {
+		t.Fatalf("unexpected method %s and encoding %s", form.Method, form.EncType)
+	}
+	if form.Action.String() != "https://example.com/login" {
+		t.Fatalf("unexpected action %s", form.Action)
+	}
+	expected := url.Values{
+		"token":    {"abc"},
+		"username": {""},
+		"password": {""},
+		"remember": {"on"},
+		"lang":     {"nl"},
+		"note":     {"hello"},
+	}
+	if form.Values.Encode() != expected.Encode() {
+		t.Fatalf("expected values %s, got %s", expected.Encode(), form.Values.Encode())
+	}
+
+	form.Set("username", "alice")
+	form.Set("password", "secret")
+	req, err := form.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.Session != "alice" || req.Depth != 1 {
+		t.Fatalf("unexpected request %s, session %q, depth %d", req.Method, req.Session, req.Depth)
+	}
+	httpRequest, err := req.HTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpRequest.Header.Get("Content-Type") != request.FormURLEncoded {
+		t.Fatalf("unexpected content type %s", httpRequest.Header.Get("Content-Type"))
+	}
+	if err := httpRequest.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	if httpRequest.PostForm.Get("username") != "alice" || httpRequest.PostForm.Get("token") != "abc" {
+		t.Fatalf("unexpected body %v", httpRequest.PostForm)
+	}
+
+	// payload and headers survive the queue
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &request.Request{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.Payload) != string(req.Payload) || decoded.Header.Get("Content-Type") != request.FormURLEncoded || decoded.Method != "POST" {
+		t.Fatalf("request not preserved: %s", data)
+	}
+}
+
+func TestFormGet(t *testing.T) {
+	res := newFormResponse(t, loginPage)
+	form, err := res.Form("#search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Set("q", "spiders")
+	req, err := form.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "GET" || req.URL.String() != "https://example.com/search?q=spiders" || len(req.Payload) != 0 {
+		t.Fatalf("unexpected request %s %s", req.Method, req.URL)
+	}
+
+	if _, err := res.Form("#missing"); err == nil {
+		t.Fatal("expected FormNotFound")
+	} else if _, ok := err.(request.FormNotFound); !ok {
+		t.Fatalf("expected FormNotFound, got %v", err)
+	}
+}
+
+func TestFormMultipart(t *testing.T) {
+	res := newFormResponse(t, loginPage)
+	form, err := res.Form("#search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.SetFile("upload", "notes.txt", []byte("file content"))
+	req, err := form.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRequest, err := req.HTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := httpRequest.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if httpRequest.MultipartForm.Value["q"][0] != "wander" {
+		t.Fatalf("unexpected values %v", httpRequest.MultipartForm.Value)
+	}
+	files := httpRequest.MultipartForm.File["upload"]
+	if len(files) != 1 || files[0].Filename != "notes.txt" {
+		t.Fatalf("unexpected files %v", files)
+	}
+	var file multipart.File
+	if file, err = files[0].Open(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(file)
+	if string(content) != "file content" {
+		t.Fatalf("unexpected file content %q", content)
+	}
+}
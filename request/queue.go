@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 type QueueResult struct {
-	Error   error
-	Request *Request
+	Error    error
+	Request  *Request
+	Priority int
 }
 
 // Queue is a prioritized FIFO queue for requests
@@ -16,6 +18,8 @@ type Queue interface {
 	io.Closer
 	// Enqueue adds the request to the queue, returns an error if no more space is available.
 	Enqueue(req *Request, priority int) error
+	// EnqueueAt adds the request to the queue, it is not dequeued before the given time.
+	EnqueueAt(req *Request, priority int, at time.Time) error
 	// Dequeue pops the highest priority request from the queue.
 	Dequeue() <-chan QueueResult
 	// Count returns the amount of queued requests.
@@ -38,6 +42,13 @@ type heapNode struct {
 	request        *Request
 }
 
+// scheduledNode is a request waiting to be added to the heap.
+type scheduledNode struct {
+	at       time.Time
+	priority int
+	request  *Request
+}
+
 func less(a, b heapNode) bool {
 	if a.priority < b.priority {
 		return true
@@ -60,6 +71,9 @@ type RequestHeapQueue struct {
 	waitCondition  *sync.Cond
 	waitGroup      *sync.WaitGroup
 	isDone         bool
+	// scheduled holds the requests added with EnqueueAt that are not due yet, ordered by time
+	scheduled []scheduledNode
+	timer     *time.Timer
 }
 
 // NewRequestHeap returns a request heap (priority queue).
@@ -95,10 +109,68 @@ func (r *RequestHeapQueue) Enqueue(req *Request, priority int) error {
 	return r.insert(req, priority)
 }
 
+// EnqueueAt adds a request with the given priority, it is not dequeued before the given time.
+func (r *RequestHeapQueue) EnqueueAt(req *Request, priority int, at time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return r.insert(req, priority)
+	}
+	if r.count+len(r.scheduled) >= r.maxSize {
+		return &QueueMaxSize{size: r.maxSize}
+	}
+
+	i := len(r.scheduled)
+	for i > 0 && r.scheduled[i-1].at.After(at) {
+		i--
+	}
+	r.scheduled = append(r.scheduled, scheduledNode{})
+	copy(r.scheduled[i+1:], r.scheduled[i:])
+	r.scheduled[i] = scheduledNode{
+		at:       at,
+		priority: priority,
+		request:  req,
+	}
+
+	if i == 0 {
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		r.timer = time.AfterFunc(wait, r.insertScheduled)
+	}
+	return nil
+}
+
+// insertScheduled adds the scheduled requests that are due to the heap.
+func (r *RequestHeapQueue) insertScheduled() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	for len(r.scheduled) > 0 && !r.scheduled[0].at.After(now) {
+		node := r.scheduled[0]
+		r.scheduled = r.scheduled[1:]
+		r.insert(node.request, node.priority)
+	}
+	if len(r.scheduled) > 0 && !r.isDone {
+		r.timer = time.AfterFunc(r.scheduled[0].at.Sub(now), r.insertScheduled)
+	}
+}
+
 func (r *RequestHeapQueue) Dequeue() <-chan QueueResult {
 	outlet := make(chan QueueResult)
+	// register the consumer under the lock so Close can't be waiting concurrently
+	r.lock.Lock()
+	if r.isDone {
+		r.lock.Unlock()
+		return outlet
+	}
+	r.waitGroup.Add(1)
+	r.lock.Unlock()
+
 	go func() {
-		r.waitGroup.Add(1)
 		r.waitCondition.L.Lock()
 
 		// wait untl an item is available or Close is called
@@ -109,10 +181,11 @@ func (r *RequestHeapQueue) Dequeue() <-chan QueueResult {
 		if r.isDone {
 			r.waitCondition.L.Unlock()
 		} else {
-			req := r.extract()
+			node := r.extract()
 			r.waitCondition.L.Unlock()
 			outlet <- QueueResult{
-				Request: req,
+				Request:  node.request,
+				Priority: node.priority,
 			}
 
 		}
@@ -124,7 +197,12 @@ func (r *RequestHeapQueue) Dequeue() <-chan QueueResult {
 }
 
 func (r *RequestHeapQueue) Close() error {
+	r.lock.Lock()
 	r.isDone = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.lock.Unlock()
 	r.waitCondition.Broadcast()
 	r.waitGroup.Wait()
 	return nil
@@ -134,15 +212,25 @@ func (r *RequestHeapQueue) Clear() {
 	for i := range r.data {
 		r.data[i] = heapNode{}
 	}
+	r.lock.Lock()
+	r.scheduled = nil
+	r.lock.Unlock()
 }
 
-// Count returns the amount of requests in the queue.
+// Count returns the amount of requests in the queue, including scheduled requests.
 func (r *RequestHeapQueue) Count() (int, error) {
-	return r.count, nil
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.count + len(r.scheduled), nil
 }
 
 // insert a request.
 func (r *RequestHeapQueue) insert(req *Request, priority int) error {
+	// keep room for the scheduled requests
+	if r.count+len(r.scheduled) >= r.maxSize {
+		return &QueueMaxSize{size: r.maxSize}
+	}
+
 	node := heapNode{
 		priority:       priority,
 		request:        req,
@@ -180,12 +268,12 @@ func (r *RequestHeapQueue) insert(req *Request, priority int) error {
 }
 
 // extract the root node and replace it with the last element, then sift down.
-func (r *RequestHeapQueue) extract() *Request {
-	req := r.data[0].request
+func (r *RequestHeapQueue) extract() heapNode {
+	node := r.data[0]
 	r.count--
 	r.data[0] = r.data[r.count]
 	r.maxHeapify(0)
-	return req
+	return node
 }
 
 // Sort the heap so that the highest priority request is the root node
@@ -3,6 +3,7 @@ package request_test
 import (
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/KillianMeersman/wander/request"
 	"github.com/KillianMeersman/wander/util"
@@ -107,6 +108,62 @@ func TestRequestHeapDifferentPriority(t *testing.T) {
 	}
 }
 
+func testQueueEnqueueAt(t *testing.T, queue request.Queue) {
+	requests, err := randomRequests(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.EnqueueAt(requests[0], 10, time.Now().Add(300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queue.Enqueue(requests[1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := queue.Count(); err != nil || count != 2 {
+		t.Fatalf("expected 2 queued requests, got %d %v", count, err)
+	}
+
+	// the scheduled request is not dequeued before its time, regardless of its priority
+	res := <-queue.Dequeue()
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if res.Request.URL.Host != requests[1].URL.Host {
+		t.Fatal("scheduled request dequeued before its time")
+	}
+
+	start := time.Now()
+	res = <-queue.Dequeue()
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if res.Request.URL.Host != requests[0].URL.Host || res.Priority != 10 {
+		t.Fatal("scheduled request not dequeued with its priority")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("scheduled request dequeued too late")
+	}
+}
+
+func TestRequestHeapEnqueueAt(t *testing.T) {
+	heap := request.NewRequestHeap(10)
+	defer heap.Close()
+	testQueueEnqueueAt(t, heap)
+}
+
+func TestRequestRedisEnqueueAt(t *testing.T) {
+	queue, err := request.NewRedisQueue("localhost", 6379, "", "requests_scheduled", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Clear()
+	defer queue.Close()
+	testQueueEnqueueAt(t, queue)
+}
+
 func TestRequestRedisEqualPriority(t *testing.T) {
 	requests, err := randomRequests(1000)
 	if err != nil {
@@ -30,12 +30,12 @@ func NewRedisCache(host string, port int, password, key string, db int) (*RedisC
 }
 
 func (r *RedisCache) AddRequest(req *Request) error {
-	res := r.client.HSet(r.key, req.URL.String(), "t")
+	res := r.client.HSet(r.key, cacheKey(req), "t")
 	return res.Err()
 }
 
 func (r *RedisCache) VisitedURL(req *Request) (bool, error) {
-	res := r.client.HGet(r.key, req.URL.String())
+	res := r.client.HGet(r.key, cacheKey(req))
 	val, err := res.Result()
 	if err != nil && err.Error() == "redis: nil" {
 		err = nil
@@ -0,0 +1,167 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// expireScript sets the expiry of a key, an existing expiry is only replaced if it ends sooner.
+//
+// KEYS[1] key.
+// ARGV[1] time to live in milliseconds.
+var expireScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl ~= -2 and ttl < tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// DefaultSessionCookieTTL is the default time cookies without an expiry time are kept in Redis.
+const DefaultSessionCookieTTL = 24 * time.Hour
+
+// RedisCookieJars holds cookie jars in Redis, allowing sessions to be shared between processes and to survive restarts.
+// The cookies of a session are stored in a Redis hash per domain, only the hashes of the host and its parent domains are read for a request.
+// Hashes expire with the latest cookie they hold.
+type RedisCookieJars struct {
+	// SessionCookieTTL is how long cookies without an expiry time are kept, defaults to DefaultSessionCookieTTL.
+	SessionCookieTTL time.Duration
+	client           *redis.Client
+	key              string
+}
+
+// NewRedisCookieJars instantiates a new Redis cookie jar collection.
+func NewRedisCookieJars(host string, port int, password, key string, db int) (*RedisCookieJars, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+		DB:       db,
+	})
+
+	_, err := client.Ping().Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisCookieJars{
+		SessionCookieTTL: DefaultSessionCookieTTL,
+		client:           client,
+		key:              key,
+	}, nil
+}
+
+// Jar returns the cookie jar for a session.
+func (r *RedisCookieJars) Jar(session string) (http.CookieJar, error) {
+	return &redisJar{
+		client:     r.client,
+		key:        fmt.Sprintf("%s:%s", r.key, session),
+		sessionTTL: r.SessionCookieTTL,
+	}, nil
+}
+
+// Clear removes all cookies of all sessions.
+func (r *RedisCookieJars) Clear() error {
+	keys, err := r.client.Keys(r.key + ":*").Result()
+	if err != nil {
+		return err
+	}
+	if len(keys) < 1 {
+		return nil
+	}
+	return r.client.Del(keys...).Err()
+}
+
+// redisJar is the cookie jar of a single session stored in Redis.
+// The http.CookieJar interface does not allow errors to be returned, cookies are not stored or returned if Redis cannot be reached.
+type redisJar struct {
+	client     *redis.Client
+	key        string
+	sessionTTL time.Duration
+}
+
+// SetCookies implements the http.CookieJar interface.
+// Deleted and expired cookies are removed from Redis.
+func (j *redisJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	now := time.Now()
+	pipe := j.client.Pipeline()
+	ttls := make(map[string]time.Duration)
+	for _, cookie := range cookies {
+		record := newCookieRecord(u, cookie, now)
+		key := j.domainKey(record.domain())
+		if record.Cookie.MaxAge < 0 || (!record.Cookie.Expires.IsZero() && !record.Cookie.Expires.After(now)) {
+			pipe.HDel(key, record.key())
+			continue
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		pipe.HSet(key, record.key(), data)
+
+		ttl := j.sessionTTL
+		if !record.Cookie.Expires.IsZero() {
+			ttl = record.Cookie.Expires.Sub(now)
+		}
+		if ttl > ttls[key] {
+			ttls[key] = ttl
+		}
+	}
+	for key, ttl := range ttls {
+		// EVALSHA can't fall back to EVAL in a pipeline
+		expireScript.Eval(pipe, []string{key}, int64(ttl/time.Millisecond)+1)
+	}
+	pipe.Exec()
+}
+
+// Cookies implements the http.CookieJar interface.
+func (j *redisJar) Cookies(u *url.URL) []*http.Cookie {
+	pipe := j.client.Pipeline()
+	results := make([]*redis.StringStringMapCmd, 0)
+	for _, domain := range cookieDomains(u.Hostname()) {
+		results = append(results, pipe.HGetAll(j.domainKey(domain)))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil
+	}
+
+	records := make(map[string]cookieRecord)
+	for _, result := range results {
+		for key, value := range result.Val() {
+			record := cookieRecord{}
+			if err := json.Unmarshal([]byte(value), &record); err != nil || record.Cookie == nil {
+				continue
+			}
+			records[key] = record
+		}
+	}
+	if len(records) < 1 {
+		return nil
+	}
+	return replay(records).Cookies(u)
+}
+
+func (j *redisJar) domainKey(domain string) string {
+	return fmt.Sprintf("%s:%s", j.key, domain)
+}
+
+// cookieDomains returns the domains whose cookies may be sent to a host, the host and its parent domains.
+func cookieDomains(host string) []string {
+	host = strings.ToLower(host)
+	domains := []string{host}
+	if net.ParseIP(host) != nil {
+		return domains
+	}
+	for i := strings.IndexByte(host, '.'); i > -1; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		domains = append(domains, host)
+	}
+	return domains
+}
@@ -10,6 +10,29 @@ import (
 	"github.com/go-redis/redis/v7"
 )
 
+// releaseScript moves the scheduled requests that are due to the queue.
+//
+// KEYS[1] queue key.
+// KEYS[2] scheduled requests key.
+// ARGV[1] current time in milliseconds.
+var releaseScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, item in ipairs(items) do
+	local scheduled = cjson.decode(item)
+	redis.call('ZADD', KEYS[1], scheduled.priority, scheduled.request)
+	redis.call('ZREM', KEYS[2], item)
+end
+return #items
+`)
+
+// scheduledRequest is a request waiting in the scheduled requests set.
+type scheduledRequest struct {
+	Priority int    `json:"priority"`
+	Request  string `json:"request"`
+}
+
+// RedisQueue is a request.Queue shared between processes through Redis.
+// Requests added with EnqueueAt are kept in a second sorted set scored by time until they are due.
 type RedisQueue struct {
 	client    *redis.Client
 	key       string
@@ -50,15 +73,54 @@ func (r *RedisQueue) Enqueue(req *Request, priority int) error {
 	return res.Err()
 }
 
+// EnqueueAt adds a request with the given priority, it is not dequeued before the given time.
+func (r *RedisQueue) EnqueueAt(req *Request, priority int, at time.Time) error {
+	if !at.After(time.Now()) {
+		return r.Enqueue(req, priority)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	scheduled, err := json.Marshal(scheduledRequest{
+		Priority: priority,
+		Request:  string(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	res := r.client.ZAdd(r.scheduledKey(), &redis.Z{
+		Score:  float64(at.UnixNano() / int64(time.Millisecond)),
+		Member: scheduled,
+	})
+	return res.Err()
+}
+
+// releaseScheduled moves the scheduled requests that are due to the queue.
+func (r *RedisQueue) releaseScheduled() error {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	return releaseScript.Run(r.client, []string{r.key, r.scheduledKey()}, now).Err()
+}
+
+func (r *RedisQueue) scheduledKey() string {
+	return r.key + ":scheduled"
+}
+
 func (r *RedisQueue) Dequeue() <-chan QueueResult {
 	outlet := make(chan QueueResult)
+	r.waitGroup.Add(1)
 	go func() {
-		r.waitGroup.Add(1)
 
 		var zWithKey *redis.ZWithKey
 		var err error
 		for zWithKey == nil && !r.isDone {
-			zKeyCommand := r.client.BZPopMax(5*time.Second, r.key)
+			err = r.releaseScheduled()
+			if err != nil {
+				break
+			}
+			zKeyCommand := r.client.BZPopMax(time.Second, r.key)
 			zWithKey, err = zKeyCommand.Result()
 		}
 
@@ -88,7 +150,8 @@ func (r *RedisQueue) Dequeue() <-chan QueueResult {
 			}
 
 			outlet <- QueueResult{
-				Request: &req,
+				Request:  &req,
+				Priority: int(zWithKey.Score),
 			}
 		}
 
@@ -104,10 +167,15 @@ func (r *RedisQueue) Close() error {
 }
 
 func (r *RedisQueue) Clear() {
-	r.client.Del(r.key)
+	r.client.Del(r.key, r.scheduledKey())
 }
 
+// Count returns the amount of requests in the queue, including scheduled requests.
 func (r *RedisQueue) Count() (int, error) {
 	res := r.client.ZCount(r.key, "0", "999999999999")
-	return int(res.Val()), res.Err()
+	if res.Err() != nil {
+		return 0, res.Err()
+	}
+	scheduled := r.client.ZCard(r.scheduledKey())
+	return int(res.Val() + scheduled.Val()), scheduled.Err()
 }
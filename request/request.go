@@ -1,6 +1,9 @@
 package request
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -10,17 +13,27 @@ import (
 type Request struct {
 	http.Request
 	Depth int
+	// Session selects the cookie jar used for the request, requests inherit the session of their parent.
+	Session string
+	// Payload is the request body, kept in memory so the request can be queued and retried.
+	Payload []byte
 }
 
 func (r *Request) MarshalJSON() ([]byte, error) {
 	data := struct {
-		Depth  int
-		Method string
-		URL    *url.URL
+		Depth   int
+		Method  string
+		URL     *url.URL
+		Header  http.Header `json:",omitempty"`
+		Session string
+		Payload []byte `json:",omitempty"`
 	}{
 		r.Depth,
 		r.Method,
 		r.URL,
+		r.Header,
+		r.Session,
+		r.Payload,
 	}
 
 	return json.Marshal(data)
@@ -30,6 +43,7 @@ func (r *Request) MarshalJSON() ([]byte, error) {
 // Returns an error if the URL could not be parsed.
 func NewRequest(url *url.URL, parent *Request) (*Request, error) {
 	depth := 0
+	session := ""
 	if parent != nil {
 		if !url.IsAbs() {
 			url.Scheme = parent.URL.Scheme
@@ -37,6 +51,7 @@ func NewRequest(url *url.URL, parent *Request) (*Request, error) {
 		}
 
 		depth = parent.Depth + 1
+		session = parent.Session
 	}
 
 	req := http.Request{
@@ -47,5 +62,38 @@ func NewRequest(url *url.URL, parent *Request) (*Request, error) {
 	return &Request{
 		Request: req,
 		Depth:   depth,
+		Session: session,
 	}, nil
 }
+
+// HTTPRequest returns a new http request with the method, url, headers and payload of the request.
+func (r *Request) HTTPRequest() (*http.Request, error) {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	var req *http.Request
+	var err error
+	if r.Payload != nil {
+		req, err = http.NewRequest(method, r.URL.String(), bytes.NewReader(r.Payload))
+	} else {
+		req, err = http.NewRequest(method, r.URL.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	return req, nil
+}
+
+// payloadKey identifies the method and payload of requests other than GET requests, returns an empty string for GET requests.
+func (r *Request) payloadKey() string {
+	if r.Method == "" || r.Method == "GET" {
+		return ""
+	}
+	hash := sha1.Sum(r.Payload)
+	return r.Method + " " + hex.EncodeToString(hash[:])
+}
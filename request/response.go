@@ -1,38 +1,169 @@
 package request
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 
+	"github.com/KillianMeersman/wander/limits/robots"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
 )
 
+// Redirect is a hop in a redirect chain.
+type Redirect struct {
+	// URL is the url that responded with the redirect.
+	URL        *url.URL
+	StatusCode int
+}
+
 // Response holds the original Request, as well as the http Response and goquery document.
 // Response instances can be searched by using qoquery methods.
 type Response struct {
 	http.Response
 	Request  *Request
 	Document *goquery.Document
+	// PageRules holds the robots directives of the page, set by the spider's PageRobotExclusionFunction.
+	PageRules *robots.PageRules
+	// Redirects holds the redirects followed to get the response, in order. Empty if the request was not redirected.
+	Redirects []Redirect
+	// Truncated is true if the body was cut off at the maximum body size, see LimitBody.
+	Truncated bool
+	// WireSize is the amount of body bytes read as received, DecodedSize the amount after decoding the content encoding.
+	// Both are updated while the body is read, see DecodeBody.
+	WireSize    int64
+	DecodedSize int64
+	// Charset is the character encoding detected when parsing an HTML body, e.a. "utf-8" or "shift_jis", see DetectCharset.
+	// Empty until the body is parsed.
+	Charset string
+
+	// body holds the body once it has been read by one of the parse methods
+	body []byte
+	// contentType holds the media type once it has been determined by ContentType
+	contentType string
+	jsonDoc     *jsonquery.Node
+	xmlDoc      *xmlquery.Node
+}
+
+// FinalURL returns the url of the response after following redirects.
+func (r *Response) FinalURL() *url.URL {
+	if r.Response.Request != nil && r.Response.Request.URL != nil {
+		return r.Response.Request.URL
+	}
+	return r.Request.URL
+}
+
+// HTTPResponse returns the http response.
+func (r *Response) HTTPResponse() *http.Response {
+	return &r.Response
 }
 
 // Parse the document in a document, caches the document in the document field.
+// The body is transcoded to UTF-8 before parsing, the detected encoding is stored in the Charset field.
+// The body can still be read after parsing, it is not transcoded.
 func (r *Response) Parse() (*goquery.Document, error) {
 	if r.Document != nil {
 		return r.Document, nil
 	}
 
-	doc, err := goquery.NewDocumentFromResponse(&r.Response)
+	body, err := r.readBody()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.decodeHTML(body)))
 	if err != nil {
 		return nil, err
 	}
+	doc.Url = r.FinalURL()
 	r.Document = doc
 	return doc, nil
 }
 
+// XPath evaluates an XPath 1.0 expression against the document produced by Parse, the document is parsed only once.
+// Returns an error if the body could not be parsed or the expression is invalid.
+func (r *Response) XPath(expr string) ([]*html.Node, error) {
+	doc, err := r.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Nodes) < 1 {
+		return nil, nil
+	}
+	return htmlquery.QueryAll(doc.Nodes[0], expr)
+}
+
+// JSON parses the body as JSON, caching the result.
+// The returned node can be queried with jsonquery, using XPath expressions such as "//items/*[price>10]/name".
+// The body can still be read after parsing.
+func (r *Response) JSON() (*jsonquery.Node, error) {
+	if r.jsonDoc != nil {
+		return r.jsonDoc, nil
+	}
+
+	body, err := r.readBody()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.jsonDoc = doc
+	return doc, nil
+}
+
+// XML parses the body as XML, caching the result.
+// The returned node can be queried with xmlquery using XPath expressions.
+// The body can still be read after parsing.
+func (r *Response) XML() (*xmlquery.Node, error) {
+	if r.xmlDoc != nil {
+		return r.xmlDoc, nil
+	}
+
+	body, err := r.readBody()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.xmlDoc = doc
+	return doc, nil
+}
+
+// Bytes returns the whole body, it can still be read afterwards.
+func (r *Response) Bytes() ([]byte, error) {
+	return r.readBody()
+}
+
+// readBody reads the whole body into memory and replaces it with a reader over the contents, so it can be read again.
+func (r *Response) readBody() ([]byte, error) {
+	if r.body != nil {
+		return r.body, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.body = body
+	return body, nil
+}
+
 // NewResponse returns a Response. Returns an error if the response body could not be parsed by goquery.
 func NewResponse(req *Request, res http.Response) *Response {
 	return &Response{
-		res,
-		req,
-		nil,
+		Response:  res,
+		Request:   req,
+		PageRules: &robots.PageRules{},
+		Redirects: make([]Redirect, 0),
 	}
 }
@@ -5,9 +5,9 @@ package wander
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,10 +15,14 @@ import (
 	"github.com/KillianMeersman/wander/util"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
 
 	"github.com/KillianMeersman/wander/limits"
 
 	"github.com/KillianMeersman/wander/request"
+	"github.com/KillianMeersman/wander/structured"
 )
 
 // SpiderConstructorOption is used for chaining constructor options.
@@ -29,20 +33,39 @@ type SpiderConstructorOption func(s *Spider) error
 // It's possible to define your own RobotLimitFunction in order to e.a. ignore only certain limitations.
 type RobotLimitFunction func(spid *Spider, req *request.Request) error
 
+// PageRobotLimitFunction determines how a spider acts upon the robots directives of a page, set by <meta name="robots"> elements and X-Robots-Tag headers.
+// It is called for every response before the response callback and sets the response's PageRules.
+// The default is FollowPageRobotRules, IgnorePageRobotRules is also provided.
+type PageRobotLimitFunction func(spid *Spider, res *request.Response) error
+
+// AuthenticateFunction logs a session in, e.a. by submitting a login form using RequestNow.
+type AuthenticateFunction func(spid *Spider, session string) error
+
+// LoggedOutFunction returns true if a response shows the session has been logged out, e.a. because it redirected to a login page.
+type LoggedOutFunction func(res *request.Response) bool
+
 // UserAgentFunction determines what User-Agent the spider will use.
 type UserAgentFunction func(req *request.Request) string
 
+// SitemapPriorityFunction determines the queue priority of a location found in a sitemap.
+// The default is SitemapPriority.
+type SitemapPriorityFunction func(location robots.SitemapLocation) int
+
 // SpiderState holds a spider's state, such as the request queue and cache.
 // It is returned by the Start and Resume methods, allowing the Resume method to resume a previously stopped crawl.
 type SpiderState struct {
 	Queue request.Queue
 	Cache request.Cache
+	// Cookies holds the cookie jars of the spider's sessions, cookies are not stored if nil.
+	Cookies request.CookieJars
 }
 
 // SpiderParameters crawling parameters for a spider
 type SpiderParameters struct {
 	UserAgent              UserAgentFunction
 	RobotExclusionFunction RobotLimitFunction
+	// PageRobotExclusionFunction determines how the robots directives of pages are applied.
+	PageRobotExclusionFunction PageRobotLimitFunction
 	// DefaultWaitTime for 429 & 503 responses without a Retry-After header
 	DefaultWaitTime time.Duration
 	// MaxWaitTime for 429 & 503 responses with a Retry-After header
@@ -50,16 +73,56 @@ type SpiderParameters struct {
 	// IgnoreTimeouts if true, the bot will ignore 429 response timeouts.
 	// Defaults to false.
 	IgnoreTimeouts bool
+	// RobotRetryTime for robots.txt files that returned a 5xx response or could not be reached.
+	// All paths on the host are disallowed until the file is downloaded again after this time.
+	RobotRetryTime time.Duration
+	// SitemapScheme is the scheme used to discover the sitemaps of the allowed domains, defaults to https.
+	SitemapScheme string
+	// SitemapSince filters out sitemap locations last modified before it, for incremental crawls.
+	SitemapSince time.Time
+	// SitemapPriority determines the queue priority of sitemap locations, defaults to SitemapPriority.
+	SitemapPriority SitemapPriorityFunction
+	// MaxRedirects is the maximum amount of redirects followed per request, defaults to 10.
+	// Redirects are not followed if 0, the redirect response is returned instead.
+	MaxRedirects int
+	// MaxBodySize is the maximum size of response bodies in bytes, defaults to DefaultMaxBodySize.
+	// Bodies are not limited if 0 or less.
+	MaxBodySize int64
+	// ContentTypeBodySizes overrides MaxBodySize for media types, e.a. "application/pdf" or "video/*".
+	ContentTypeBodySizes map[string]int64
+	// TruncateBodies cuts bodies off at the maximum body size instead of aborting the response.
+	// request.BodyTooLarge is passed to the error callback in both cases.
+	TruncateBodies bool
+	// Clock returns the current time used for crawl windows, Retry-After dates and time dependent robots.txt rules, defaults to time.Now.
+	Clock func() time.Time
+}
+
+// DefaultMaxBodySize is the default maximum size of response bodies, 10MiB.
+const DefaultMaxBodySize = 10 << 20
+
+// sessionAuth tracks the authentication of a session.
+type sessionAuth struct {
+	lock sync.Mutex
+	// generation is incremented every time the session is authenticated, 0 if it has not been authenticated yet
+	generation int
+}
+
+// robotDownload is a robots.txt download shared by all ingestors requesting the same host.
+type robotDownload struct {
+	done      chan struct{}
+	robotFile *robots.RobotFile
+	err       error
 }
 
 // Spider provides a parallelized scraper.
 type Spider struct {
 	SpiderState
 	SpiderParameters
-	RobotLimits    *robots.RobotRules
+	RobotLimits    robots.RobotRules
 	AllowedDomains []string
 	limits         map[string]limits.RequestFilter
-	throttle       limits.ThrottleCollection
+	throttle       limits.HostThrottle
+	windows        *limits.CrawlWindows
 
 	// parallelism
 	ingestorN int
@@ -71,11 +134,40 @@ type Spider struct {
 	isRunning bool
 
 	// callbacks
-	requestFunc      func(*request.Request) *request.Request
-	responseFunc     func(*request.Response)
-	errorFunc        func(error)
-	selectors        map[string]func(*request.Response, *goquery.Selection)
-	pipelineDoneFunc func()
+	requestFunc         func(*request.Request) *request.Request
+	responseFunc        func(*request.Response)
+	errorFunc           func(error)
+	selectors           map[string]func(*request.Response, *goquery.Selection)
+	xpathSelectors      map[string]func(*request.Response, *html.Node)
+	jsonSelectors       map[string]func(*request.Response, *jsonquery.Node)
+	xmlSelectors        map[string]func(*request.Response, *xmlquery.Node)
+	structuredSelectors map[string]func(*request.Response, *structured.Item)
+	binaryFunc          func(*request.Response)
+	pipelineDoneFunc    func()
+	backoffStartFunc    func(host string, waitTime time.Duration)
+	backoffEndFunc      func(host string)
+
+	// backoffs holds the backoff of each host currently backing off
+	backoffs    map[string]*hostBackoff
+	backoffLock *sync.Mutex
+
+	// authentication
+	authenticateFunc AuthenticateFunction
+	loggedOutFunc    LoggedOutFunction
+	sessions         map[string]*sessionAuth
+	sessionLock      *sync.Mutex
+	// retried holds the requests added to the queue again after their session was logged out, by session and url
+	retried map[string]struct{}
+
+	// seedSitemaps is true if the allowed domains' sitemaps should be visited when the spider starts
+	seedSitemaps bool
+
+	// robotDownloads holds the robots.txt downloads in progress for each host
+	robotDownloads    map[string]*robotDownload
+	robotDownloadLock *sync.Mutex
+	// robotWindows holds the robots.txt rules each host's crawl windows were last set from
+	robotWindows     map[string]*robots.RobotFile
+	robotWindowsLock *sync.Mutex
 
 	// http
 	client *http.Client
@@ -102,7 +194,35 @@ func (s *Spider) RemoveLimits(limits ...limits.RequestFilter) {
 
 // SetThrottles sets or replaces the default and custom throttles for the spider.
 func (s *Spider) SetThrottles(def *limits.DefaultThrottle, domainThrottles ...*limits.DomainThrottle) {
-	s.throttle = limits.NewThrottleCollection(def, domainThrottles...)
+	throttle := limits.NewThrottleCollection(def, domainThrottles...)
+	s.throttle = &throttle
+}
+
+// SetHostThrottle sets or replaces the throttle for the spider.
+// Allows throttles to be shared between spiders, see limits.RedisThrottle.
+// Errors of throttles with an OnError method, such as limits.ThrottleUnavailable, are passed to the spider's error callback.
+func (s *Spider) SetHostThrottle(throttle limits.HostThrottle) {
+	if reporter, ok := throttle.(interface{ OnError(func(error)) }); ok {
+		reporter.OnError(func(err error) {
+			s.errorFunc(err)
+		})
+	}
+	s.throttle = throttle
+}
+
+// SetCrawlWindows sets or replaces the time windows during which a host may be crawled.
+// Requests to the host outside of these windows are deferred until the next window opens.
+// The host may contain wildcards, passing no windows removes all restrictions for the host.
+func (s *Spider) SetCrawlWindows(host string, windows ...*limits.TimeWindow) {
+	s.windows.SetHostWindows(host, windows...)
+}
+
+// SetAuthentication sets the function used to log sessions in before their first request, and the function detecting they have been logged out.
+// When a response shows its session has been logged out, the session is logged in again and the request is added to the queue again.
+// The logged out function may be nil, sessions are then only logged in once.
+func (s *Spider) SetAuthentication(authenticate AuthenticateFunction, loggedOut LoggedOutFunction) {
+	s.authenticateFunc = authenticate
+	s.loggedOutFunc = loggedOut
 }
 
 // SetProxyFunc sets the proxy function to be used
@@ -136,11 +256,43 @@ func (s *Spider) OnResponse(f func(res *request.Response)) {
 	s.responseFunc = f
 }
 
-// OnHTML is called for each element matching the selector in a response body
+// OnHTML is called for each element matching the selector in a response body.
+// Handlers should check res.PageRules to see whether the page may be indexed, links on nofollow pages can't be followed.
 func (s *Spider) OnHTML(selector string, f func(res *request.Response, el *goquery.Selection)) {
 	s.selectors[selector] = f
 }
 
+// OnXPath is called for each node matching the XPath 1.0 expression in an HTML response body.
+// Expressions are evaluated against the same document as the OnHTML selectors, the page is only parsed once.
+func (s *Spider) OnXPath(expr string, f func(res *request.Response, node *html.Node)) {
+	s.xpathSelectors[expr] = f
+}
+
+// OnStructuredData is called for each JSON-LD, Microdata or RDFa item of the type in an HTML or JSON-LD response, including nested items.
+// Types are matched ignoring the schema.org vocabulary, see structured.Item.Is. An empty type matches all top-level items.
+// Use structured.Extract in a response callback for the page's OpenGraph and Twitter card metadata.
+func (s *Spider) OnStructuredData(itemType string, f func(res *request.Response, item *structured.Item)) {
+	s.structuredSelectors[itemType] = f
+}
+
+// OnJSON is called for each node matching the XPath expression in a JSON response body, see request.Response.JSON.
+// Objects and arrays are elements named after their keys, array items are named "*". E.a. "//items/*[price>10]/name" selects the names of the items costing more than 10.
+func (s *Spider) OnJSON(expr string, f func(res *request.Response, node *jsonquery.Node)) {
+	s.jsonSelectors[expr] = f
+}
+
+// OnXML is called for each node matching the XPath expression in an XML response body, e.a. RSS feeds or XML APIs.
+func (s *Spider) OnXML(expr string, f func(res *request.Response, node *xmlquery.Node)) {
+	s.xmlSelectors[expr] = f
+}
+
+// OnBinary is called for responses that are neither HTML, JSON, XML nor text, e.a. PDFs and images.
+// The body has not been read, use request.Response.StreamBody to save large downloads without buffering them.
+// This will overwrite any previous callbacks set by this method.
+func (s *Spider) OnBinary(f func(res *request.Response)) {
+	s.binaryFunc = f
+}
+
 // OnError is called when an error is encountered.
 // This will overwrite any previous callbacks set by this method.
 func (s *Spider) OnError(f func(err error)) {
@@ -153,16 +305,36 @@ func (s *Spider) OnPipelineFinished(f func()) {
 	s.pipelineDoneFunc = f
 }
 
+// OnBackoffStart is called when a host starts backing off after a 429 or 503 response.
+// waitTime is the time requests to the host will be delayed for.
+// This will overwrite any previous callbacks set by this method.
+func (s *Spider) OnBackoffStart(f func(host string, waitTime time.Duration)) {
+	s.backoffStartFunc = f
+}
+
+// OnBackoffEnd is called when a host's backoff period has ended.
+// This will overwrite any previous callbacks set by this method.
+func (s *Spider) OnBackoffEnd(f func(host string)) {
+	s.backoffEndFunc = f
+}
+
 /*
 	Control/navigation functions
 */
 // Visit adds a request with the given path to the queue with maximum priority. Blocks when the queue is full until there is free space.
 // This method is meant to be used solely for setting the starting points of crawls before calling Start.
 func (s *Spider) Visit(url *url.URL) error {
+	return s.VisitSession(url, "")
+}
+
+// VisitSession adds a request with the given path to the queue with maximum priority, using the cookie jar of the given session.
+// Requests following links from the response use the same session, allowing several sessions to crawl the same site in parallel.
+func (s *Spider) VisitSession(url *url.URL, session string) error {
 	req, err := request.NewRequest(url, nil)
 	if err != nil {
 		return err
 	}
+	req.Session = session
 
 	return s.addRequest(req, util.MaxInt)
 }
@@ -178,10 +350,22 @@ func (s *Spider) VisitNow(url *url.URL) (*request.Response, error) {
 	return s.getResponse(req)
 }
 
+// RequestNow makes the request without adding it to the queue, e.a. to submit a login form.
+// It will still wait for any throttling and use the cookie jar of the request's session.
+func (s *Spider) RequestNow(req *request.Request) (*request.Response, error) {
+	return s.getResponse(req)
+}
+
 // Follow a link by adding the path to the queue, blocks when the queue is full until there is free space.
 // Unlike Visit, this method also accepts a response, allowing the url parser to convert relative urls into absolute ones and keep track of depth.
+// Returns robots.PageNoFollow if the response's page has a nofollow directive.
 func (s *Spider) Follow(url *url.URL, res *request.Response, priority int) error {
-	req, err := request.NewRequest(url, res.Request)
+	if res.PageRules != nil && res.PageRules.NoFollow {
+		return robots.PageNoFollow{URL: *res.Request.URL}
+	}
+
+	// resolve relative urls against the url the page was redirected to
+	req, err := request.NewRequest(res.FinalURL().ResolveReference(url), res.Request)
 	if err != nil {
 		return err
 	}
@@ -189,6 +373,72 @@ func (s *Spider) Follow(url *url.URL, res *request.Response, priority int) error
 	return s.addRequest(req, priority)
 }
 
+// VisitSitemaps adds the locations in the sitemaps of all allowed domains to the queue, using the SitemapPriority function to determine their priority.
+// Sitemaps are discovered using robots.DiscoverSitemaps and read in the background, one goroutine per domain, this method does not block.
+// Allowed domains containing wildcards are skipped.
+// Sitemap errors and errors adding locations to the queue are passed to the error callback, locations that were already visited are skipped silently.
+// Reading stops when the context is cancelled, the returned channel is closed when all sitemaps have been read.
+func (s *Spider) VisitSitemaps(ctx context.Context) <-chan struct{} {
+	wg := &sync.WaitGroup{}
+	for _, domain := range s.AllowedDomains {
+		if strings.ContainsAny(domain, "*$") {
+			continue
+		}
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			s.visitSitemaps(ctx, &url.URL{Scheme: s.SitemapScheme, Host: domain})
+		}(domain)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// visitSitemaps adds the locations in the sitemaps of a host to the queue.
+func (s *Spider) visitSitemaps(ctx context.Context, host *url.URL) {
+	sitemaps, err := robots.DiscoverSitemaps(host, s, s.BodySizeLimit("text/html"))
+	if err != nil {
+		s.errorFunc(err)
+		return
+	}
+	locations := make([]string, len(sitemaps))
+	for i, sitemap := range sitemaps {
+		locations[i] = sitemap.String()
+	}
+
+	walker := robots.NewSitemapWalker(s)
+	walker.Since = s.SitemapSince
+	err = walker.Walk(func(location robots.SitemapLocation) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		url, err := url.Parse(location.Loc)
+		if err != nil {
+			s.errorFunc(err)
+			return true
+		}
+		req, err := request.NewRequest(url, nil)
+		if err != nil {
+			s.errorFunc(err)
+			return true
+		}
+		err = s.addRequest(req, s.SitemapPriority(location))
+		if _, ok := err.(AlreadyVisited); err != nil && !ok {
+			s.errorFunc(err)
+		}
+		return true
+	}, locations...)
+	if err != nil {
+		s.errorFunc(err)
+	}
+}
+
 // start the spider by spawning all required ingestors/pipelines
 // This method is idempotent and will return without doing anything if the spider is already isRunning.
 func (s *Spider) start() {
@@ -199,6 +449,16 @@ func (s *Spider) start() {
 
 	s.done = make(chan struct{})
 	s.spawn(s.ingestorN)
+
+	if s.seedSitemaps {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := s.done
+		go func() {
+			<-done
+			cancel()
+		}()
+		s.VisitSitemaps(ctx)
+	}
 }
 
 // Start the spider.
@@ -270,33 +530,201 @@ func (s *Spider) filterRequestDomain(request *request.Request) bool {
 
 // RoundTrip implements the http.RoundTripper interface.
 // It will wait for any throttles before making requests.
+// Cookies of the default session are used if the spider has cookie jars.
 func (s *Spider) RoundTrip(req *http.Request) (*http.Response, error) {
+	return s.roundTrip(req, "")
+}
+
+// roundTrip waits for throttles and makes a request using the cookie jar of the session.
+// Only the MaxRedirects limit is applied to redirects.
+func (s *Spider) roundTrip(req *http.Request, session string) (*http.Response, error) {
+	client, err := s.sessionClient(session)
+	if err != nil {
+		return nil, err
+	}
+	var policyErr error
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		err := s.checkRedirectCount(via)
+		if err != http.ErrUseLastResponse {
+			policyErr = err
+		}
+		return err
+	}
 	s.throttle.Wait(req)
-	return s.client.Get(req.URL.String())
+	res, err := client.Do(req)
+	if policyErr != nil {
+		return nil, policyErr
+	}
+	return res, err
+}
+
+// sessionClient returns a copy of the spider's http client using the cookie jar of the session.
+func (s *Spider) sessionClient(session string) (*http.Client, error) {
+	client := *s.client
+	if s.Cookies == nil {
+		return &client, nil
+	}
+	jar, err := s.Cookies.Jar(session)
+	if err != nil {
+		return nil, err
+	}
+	client.Jar = jar
+	return &client, nil
 }
 
-// getResponse waits for throttles and makes a GET request.
+// getResponse waits for throttles and makes the request.
+// Redirects are followed according to the spider's redirect policy, see redirectPolicy.
 func (s *Spider) getResponse(req *request.Request) (*request.Response, error) {
+	return s.fetch(req, false)
+}
+
+// fetch waits for throttles and makes the request, recording the redirects followed.
+// The body is decoded according to its content encoding and limited to the maximum body size.
+// Redirects to urls that were visited before are refused with AlreadyVisited if checkVisited is true.
+func (s *Spider) fetch(req *request.Request, checkVisited bool) (*request.Response, error) {
 	if req == nil {
 		panic("Wander request is nil")
 	}
 
-	res, err := s.RoundTrip(&req.Request)
+	httpRequest, err := req.HTTPRequest()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.sessionClient(req.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	redirects := make([]request.Redirect, 0)
+	var policyErr error
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		err := s.redirectPolicy(req, next, via, checkVisited)
+		if err == http.ErrUseLastResponse {
+			return err
+		}
+		if err != nil {
+			policyErr = err
+			return err
+		}
+		redirects = append(redirects, request.Redirect{
+			URL:        via[len(via)-1].URL,
+			StatusCode: next.Response.StatusCode,
+		})
+		return nil
+	}
+
+	// advertise the encodings DecodeBody supports, the http client only decodes gzip by itself
+	if httpRequest.Header.Get("Accept-Encoding") == "" {
+		httpRequest.Header.Set("Accept-Encoding", request.AcceptEncoding)
+	}
+
+	s.throttle.Wait(httpRequest)
+	res, err := client.Do(httpRequest)
+	if policyErr != nil {
+		return nil, policyErr
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	doc := request.NewResponse(req, *res)
+	doc.Redirects = redirects
+	// limits apply to the decoded body, so compressed bodies can't exceed them
+	err = doc.DecodeBody()
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	err = doc.LimitBody(s.BodySizeLimit(res.Header.Get("Content-Type")), s.TruncateBodies)
 	if err != nil {
 		return nil, err
 	}
 	return doc, nil
 }
 
+// BodySizeLimit returns the maximum body size for a Content-Type header.
+// Limits for the exact media type take precedence over limits for the type's wildcard, e.a. "image/*", MaxBodySize is used if there are neither.
+func (s *Spider) BodySizeLimit(contentType string) int64 {
+	mediaType := request.ParseMediaType(contentType)
+	if limit, ok := s.ContentTypeBodySizes[mediaType]; ok {
+		return limit
+	}
+	if i := strings.Index(mediaType, "/"); i > -1 {
+		if limit, ok := s.ContentTypeBodySizes[mediaType[:i]+"/*"]; ok {
+			return limit
+		}
+	}
+	return s.MaxBodySize
+}
+
+// checkRedirectCount returns TooManyRedirects if another redirect would exceed MaxRedirects.
+// Returns http.ErrUseLastResponse if redirects should not be followed at all.
+func (s *Spider) checkRedirectCount(via []*http.Request) error {
+	if s.MaxRedirects <= 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > s.MaxRedirects {
+		return TooManyRedirects{URL: *via[0].URL, Max: s.MaxRedirects}
+	}
+	return nil
+}
+
+// redirectPolicy checks whether a redirect of the request may be followed.
+// Every hop must be in the allowed domains and allowed by the RobotExclusionFunction, at most MaxRedirects hops are followed.
+func (s *Spider) redirectPolicy(req *request.Request, next *http.Request, via []*http.Request, checkVisited bool) error {
+	if err := s.checkRedirectCount(via); err != nil {
+		return err
+	}
+
+	hop, err := request.NewRequest(next.URL, nil)
+	if err != nil {
+		return err
+	}
+	hop.Depth = req.Depth
+	hop.Session = req.Session
+	if !s.filterRequestDomain(hop) {
+		return limits.ForbiddenDomain{URL: *hop.URL}
+	}
+	if checkVisited {
+		visited, err := s.Cache.VisitedURL(hop)
+		if err != nil {
+			return err
+		}
+		if visited {
+			return AlreadyVisited{*hop.URL}
+		}
+	}
+	if err := s.RobotExclusionFunction(s, hop); err != nil {
+		return err
+	}
+	s.throttle.Wait(next)
+	return nil
+}
+
+// addRedirectsToCache marks the urls a response was redirected to as visited.
+func (s *Spider) addRedirectsToCache(res *request.Response) error {
+	for _, redirect := range res.Redirects[1:] {
+		if err := s.addURLToCache(redirect.URL, res.Request); err != nil {
+			return err
+		}
+	}
+	return s.addURLToCache(res.FinalURL(), res.Request)
+}
+
+// addURLToCache marks a url as visited by the session of the request.
+func (s *Spider) addURLToCache(u *url.URL, req *request.Request) error {
+	visited, err := request.NewRequest(u, nil)
+	if err != nil {
+		return err
+	}
+	visited.Session = req.Session
+	return s.Cache.AddRequest(visited)
+}
+
 // addRequest adds a request to the queue.
 func (s *Spider) addRequest(req *request.Request, priority int) error {
 	if !s.filterRequestDomain(req) {
-		return limits.ForbiddenDomain{*req.URL}
+		return limits.ForbiddenDomain{URL: *req.URL}
 	}
 
 	for _, limit := range s.limits {
@@ -329,6 +757,84 @@ func (s *Spider) addRequest(req *request.Request, priority int) error {
 	return nil
 }
 
+// deferRequest adds a request to the queue again, scheduled for when its host's crawl window opens.
+// Returns false if the request is allowed now.
+func (s *Spider) deferRequest(req *request.Request, priority int) bool {
+	now := s.Clock()
+	next := s.windows.NextAllowed(&req.Request, now)
+	if !next.After(now) {
+		return false
+	}
+
+	// queues schedule by the system time, which may differ from the spider's clock
+	err := s.Queue.EnqueueAt(req, priority, time.Now().Add(next.Sub(now)))
+	if err != nil {
+		s.errorFunc(err)
+	}
+	return true
+}
+
+// authenticate logs the session in if it has not been logged in yet or if it is still at the given generation, returns the current generation.
+// Concurrent calls for the same session log it in once.
+func (s *Spider) authenticate(session string, generation int) (int, error) {
+	if s.authenticateFunc == nil {
+		return 0, nil
+	}
+
+	s.sessionLock.Lock()
+	auth, ok := s.sessions[session]
+	if !ok {
+		auth = &sessionAuth{}
+		s.sessions[session] = auth
+	}
+	s.sessionLock.Unlock()
+
+	auth.lock.Lock()
+	defer auth.lock.Unlock()
+	if auth.generation != generation {
+		return auth.generation, nil
+	}
+	err := s.authenticateFunc(s, session)
+	if err != nil {
+		return auth.generation, err
+	}
+	auth.generation++
+	return auth.generation, nil
+}
+
+// reauthenticate logs the session of a request in again and adds the request to the queue again.
+// The session is only logged in again if no other request has done so since the request was made.
+// Requests are only retried once, LoggedOut is passed to the error callback if the session is logged out again.
+func (s *Spider) reauthenticate(req *request.Request, priority int, generation int) {
+	key := retryKey(req)
+	s.sessionLock.Lock()
+	_, retried := s.retried[key]
+	if retried {
+		delete(s.retried, key)
+	} else {
+		s.retried[key] = struct{}{}
+	}
+	s.sessionLock.Unlock()
+	if retried {
+		s.errorFunc(LoggedOut{URL: *req.URL, Session: req.Session})
+		return
+	}
+
+	_, err := s.authenticate(req.Session, generation)
+	if err != nil {
+		s.errorFunc(err)
+		return
+	}
+	err = s.Queue.Enqueue(req, priority)
+	if err != nil {
+		s.errorFunc(err)
+	}
+}
+
+func retryKey(req *request.Request) string {
+	return req.Session + " " + req.URL.String()
+}
+
 // spawn spawns a new ingestor goroutine.
 // Ingestors make requests and handle callbacks.
 func (s *Spider) spawn(n int) {
@@ -345,36 +851,26 @@ func (s *Spider) spawn(n int) {
 						s.errorFunc(req.Error)
 						return
 					}
+					if s.deferRequest(req.Request, req.Priority) {
+						continue
+					}
 
 					// Run the request callback and execute the request.
 					newRequest := s.requestFunc(req.Request)
 					if newRequest == nil {
 						continue
 					}
-					res, err := s.getResponse(newRequest)
+					generation, err := s.authenticate(newRequest.Session, 0)
 					if err != nil {
 						s.errorFunc(err)
-						return
+						continue
 					}
-
-					s.CheckResponseStatus(res)
-					s.responseFunc(res)
-
-					// If there are selectors, parse the document and run the selector callbacks.
-					if len(s.selectors) > 0 {
-						_, err := res.Parse()
-						if err != nil {
-							s.errorFunc(err)
-							continue
-						}
-						for selector, pipeline := range s.selectors {
-							res.Document.Find(selector).Each(func(i int, el *goquery.Selection) {
-								pipeline(res, el)
-							})
-						}
+					res, err := s.fetch(newRequest, true)
+					if err != nil {
+						s.errorFunc(err)
+						continue
 					}
-
-					s.pipelineDoneFunc()
+					s.handleResponse(newRequest, req.Priority, generation, res)
 				}
 
 			}
@@ -382,45 +878,300 @@ func (s *Spider) spawn(n int) {
 	}
 }
 
+// handleResponse runs the pipeline for a response, unless the response shows its session has been logged out.
+func (s *Spider) handleResponse(req *request.Request, priority, generation int, res *request.Response) {
+	if s.loggedOutFunc != nil {
+		if s.loggedOutFunc(res) {
+			res.Body.Close()
+			s.reauthenticate(req, priority, generation)
+			return
+		}
+		s.sessionLock.Lock()
+		delete(s.retried, retryKey(req))
+		s.sessionLock.Unlock()
+	}
+
+	s.runPipeline(res)
+	s.pipelineDoneFunc()
+}
+
+// runPipeline runs the response callbacks and selectors, the body is closed afterwards.
+func (s *Spider) runPipeline(res *request.Response) {
+	defer res.Body.Close()
+
+	if len(res.Redirects) > 0 {
+		if err := s.addRedirectsToCache(res); err != nil {
+			s.errorFunc(err)
+		}
+	}
+
+	if err := s.CheckResponseStatus(res); err != nil {
+		s.errorFunc(err)
+	}
+	if err := s.PageRobotExclusionFunction(s, res); err != nil {
+		s.errorFunc(err)
+		if _, ok := err.(request.BodyTooLarge); ok {
+			return
+		}
+	}
+	s.responseFunc(res)
+
+	// Run the callbacks for the response's kind of content.
+	if err := s.dispatch(res); err != nil {
+		s.errorFunc(err)
+		return
+	}
+
+	if res.Truncated {
+		s.errorFunc(request.BodyTooLarge{
+			URL:       *res.Request.URL,
+			Limit:     s.BodySizeLimit(res.Header.Get("Content-Type")),
+			Truncated: true,
+		})
+	}
+}
+
+// dispatch parses the response according to its kind of content and runs the matching selector callbacks.
+// Documents are only parsed if there are selectors for them. Returns an error if the body could not be parsed,
+// errors evaluating XPath expressions are passed to the error callback.
+func (s *Spider) dispatch(res *request.Response) error {
+	if err := s.dispatchStructuredData(res); err != nil {
+		return err
+	}
+
+	switch res.ContentKind() {
+	case request.ContentHTML:
+		if len(s.selectors) == 0 && len(s.xpathSelectors) == 0 {
+			return nil
+		}
+		doc, err := res.Parse()
+		if err != nil {
+			return err
+		}
+		for selector, pipeline := range s.selectors {
+			doc.Find(selector).Each(func(i int, el *goquery.Selection) {
+				pipeline(res, el)
+			})
+		}
+		for expr, pipeline := range s.xpathSelectors {
+			nodes, err := res.XPath(expr)
+			if err != nil {
+				s.errorFunc(err)
+				continue
+			}
+			for _, node := range nodes {
+				pipeline(res, node)
+			}
+		}
+
+	case request.ContentJSON:
+		if len(s.jsonSelectors) == 0 {
+			return nil
+		}
+		doc, err := res.JSON()
+		if err != nil {
+			return err
+		}
+		for expr, pipeline := range s.jsonSelectors {
+			nodes, err := jsonquery.QueryAll(doc, expr)
+			if err != nil {
+				s.errorFunc(err)
+				continue
+			}
+			for _, node := range nodes {
+				pipeline(res, node)
+			}
+		}
+
+	case request.ContentXML:
+		if len(s.xmlSelectors) == 0 {
+			return nil
+		}
+		doc, err := res.XML()
+		if err != nil {
+			return err
+		}
+		for expr, pipeline := range s.xmlSelectors {
+			nodes, err := xmlquery.QueryAll(doc, expr)
+			if err != nil {
+				s.errorFunc(err)
+				continue
+			}
+			for _, node := range nodes {
+				pipeline(res, node)
+			}
+		}
+
+	case request.ContentBinary:
+		s.binaryFunc(res)
+	}
+	return nil
+}
+
+// dispatchStructuredData extracts the structured data of HTML and JSON-LD responses if there are structured data callbacks, and runs them.
+// Returns an error if the body could not be parsed, invalid JSON-LD blocks are passed to the error callback.
+func (s *Spider) dispatchStructuredData(res *request.Response) error {
+	if len(s.structuredSelectors) == 0 {
+		return nil
+	}
+	if res.ContentKind() != request.ContentHTML && res.ContentType() != "application/ld+json" {
+		return nil
+	}
+
+	data, err := structured.Extract(res)
+	if data == nil {
+		return err
+	}
+	if err != nil {
+		s.errorFunc(err)
+	}
+	for itemType, pipeline := range s.structuredSelectors {
+		items := data.Items()
+		if itemType != "" {
+			items = data.Find(itemType)
+		}
+		for _, item := range items {
+			pipeline(res, item)
+		}
+	}
+	return nil
+}
+
 // DownloadRobotLimits downloads and parses the robots.txt file for a domain.
 // Respects the spider throttles.
+// Concurrent calls for the same host share a single download.
 func (s *Spider) DownloadRobotLimits(req *request.Request) (*robots.RobotFile, error) {
+	host := req.URL.Host
+	s.robotDownloadLock.Lock()
+	if download, ok := s.robotDownloads[host]; ok {
+		s.robotDownloadLock.Unlock()
+		<-download.done
+		return download.robotFile, download.err
+	}
+	download := &robotDownload{done: make(chan struct{})}
+	s.robotDownloads[host] = download
+	s.robotDownloadLock.Unlock()
+
+	download.robotFile, download.err = s.downloadRobotLimits(req)
+	s.robotDownloadLock.Lock()
+	delete(s.robotDownloads, host)
+	s.robotDownloadLock.Unlock()
+	close(download.done)
+	return download.robotFile, download.err
+}
+
+func (s *Spider) downloadRobotLimits(req *request.Request) (*robots.RobotFile, error) {
 	url := &url.URL{
 		Scheme: req.URL.Scheme,
 		Host:   req.URL.Host,
 		Path:   "/robots.txt",
 	}
-	robotFile, err := robots.NewRobotFileFromURL(url, s)
+	robotFile, err := robots.NewRobotFileFromURL(url, robotsTransport{s})
 	if err != nil {
 		return nil, err
 	}
-	s.RobotLimits.AddLimits(robotFile, req.URL.Host)
+	if robotFile.Status() == robots.RobotFileUnreachable {
+		// all paths are disallowed until the file is downloaded again
+		robotFile.SetExpires(time.Now().Add(s.RobotRetryTime))
+	}
+	err = s.RobotLimits.AddLimits(robotFile, req.URL.Host)
+	if err != nil {
+		// the file can still be used, it will be downloaded again for the next request
+		s.errorFunc(err)
+	}
 	return robotFile, nil
 }
 
+// robotsTransport makes requests for robots.txt files.
+// Unlike Spider.RoundTrip it does not follow redirects, leaving this to the robots package.
+type robotsTransport struct {
+	spider *Spider
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+// It will wait for any throttles before making requests.
+func (t robotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.spider.throttle.Wait(req)
+	transport := t.spider.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
 // CheckResponseStatus checks the response for any non-standard status codes.
-// It will apply additional throttling when it encounters a 429 or 503 status code, according to the spider parameters.
-func (s *Spider) CheckResponseStatus(res *request.Response) {
-	if !s.IgnoreTimeouts && (res.StatusCode == 429 || res.StatusCode == 503) {
-		retryAfter := res.Header.Get("Retry-After")
-		if len(retryAfter) > 0 {
-			// Parse Retry-Duration as RFC1123 timestamp
-			retryTime, err := time.Parse(time.RFC1123, retryAfter)
-			if err != nil {
-				// Parse Retry-Duration as seconds
-				retryAfterDuration, err := time.ParseDuration(fmt.Sprintf("%ss", retryAfter))
-				if err != nil {
+// It will apply additional throttling to the responding host when it encounters a 429 or 503 status code, according to the spider parameters.
+// Returns limits.InvalidRetryAfter if the Retry-After header could not be parsed, the default wait time is used in that case.
+func (s *Spider) CheckResponseStatus(res *request.Response) error {
+	if s.IgnoreTimeouts || (res.StatusCode != 429 && res.StatusCode != 503) {
+		return nil
+	}
 
-				}
-				retryTime = time.Now().Add(retryAfterDuration)
-			}
-			waitDuration := retryTime.Sub(time.Now())
-			s.throttle.SetWaitTime(waitDuration)
-		} else {
-			// No Retry-After header, use the default wait time
-			s.throttle.SetWaitTime(s.DefaultWaitTime)
+	// No Retry-After header, use the default wait time
+	waitTime := s.DefaultWaitTime
+	var err error
+	retryAfter := res.Header.Get("Retry-After")
+	if len(retryAfter) > 0 {
+		var retryAfterDuration time.Duration
+		retryAfterDuration, err = limits.ParseRetryAfter(retryAfter, s.Clock())
+		if err == nil {
+			waitTime = retryAfterDuration
 		}
 	}
+	if waitTime > s.MaxWaitTime {
+		waitTime = s.MaxWaitTime
+	}
+
+	s.backoff(res.FinalURL().Host, waitTime)
+	return err
+}
+
+// hostBackoff tracks a host backing off, timer emits the end event at end.
+type hostBackoff struct {
+	timer *time.Timer
+	end   time.Time
+}
+
+// backoff makes all requests to a host wait for the given duration and emits the backoff events.
+// A backoff is only ever extended, a shorter wait time does not end a longer backoff early.
+func (s *Spider) backoff(host string, waitTime time.Duration) {
+	if waitTime <= 0 {
+		return
+	}
+	s.throttle.SetHostWaitTime(host, waitTime)
+
+	s.backoffLock.Lock()
+	defer s.backoffLock.Unlock()
+
+	end := time.Now().Add(waitTime)
+	current, ok := s.backoffs[host]
+	if ok && !end.After(current.end) {
+		return
+	}
+	// extend the current backoff if the host is already backing off
+	if ok && current.timer.Stop() {
+		current.end = end
+		current.timer.Reset(waitTime)
+		return
+	}
+
+	s.backoffStartFunc(host, waitTime)
+	backoff := &hostBackoff{end: end}
+	backoff.timer = time.AfterFunc(waitTime, func() {
+		s.backoffLock.Lock()
+		if s.backoffs[host] == backoff {
+			delete(s.backoffs, host)
+		}
+		s.backoffLock.Unlock()
+		s.backoffEndFunc(host)
+	})
+	s.backoffs[host] = backoff
+}
+
+// SitemapPriority maps the sitemap priority of a location, between 0 and 1, to a queue priority between 0 and 1000.
+// Implementation of SitemapPriorityFunction.
+func SitemapPriority(location robots.SitemapLocation) int {
+	return int(location.Priority * 1000)
 }
 
 /*
@@ -445,15 +1196,72 @@ func FollowRobotRules(s *Spider, req *request.Request) error {
 	}
 
 	// check if the rules allow this request
-	if !rules.Allowed(s.UserAgent(req), req.URL.Path) {
-		return robots.RobotDenied{*req.URL}
+	if !rules.Allowed(s.UserAgent(req), req.URL.RequestURI()) {
+		return robots.RobotDenied{URL: *req.URL}
 	}
 
-	// check crawl-delay
+	// check crawl-delay and request-rate, the strictest of both is used
 	delay := rules.GetDelay(s.UserAgent(req), -1)
+	if interval := rules.GetRequestInterval(s.UserAgent(req), s.Clock(), -1); interval > delay {
+		delay = interval
+	}
 	if delay > -1 {
 		// override spider throttle for this domain with the given crawl delay
 		s.throttle.SetDomainThrottle(limits.NewDomainThrottle(req.URL.Host, delay))
 	}
+
+	// check visit-time, crawling outside of these windows is deferred
+	s.setRobotWindows(req, rules)
+	return nil
+}
+
+// setRobotWindows sets the crawl windows of the request's host to the Visit-time directives of its robots.txt rules.
+// The windows are only set when the rules have been (re)loaded, windows set by earlier rules are removed if the new rules have none.
+func (s *Spider) setRobotWindows(req *request.Request, rules *robots.RobotFile) {
+	host := req.URL.Host
+	userAgent := s.UserAgent(req)
+	s.robotWindowsLock.Lock()
+	defer s.robotWindowsLock.Unlock()
+
+	previous := s.robotWindows[host]
+	if previous == rules {
+		return
+	}
+	s.robotWindows[host] = rules
+
+	visitTimes := rules.GetVisitTimes(userAgent)
+	if len(visitTimes) < 1 {
+		if previous != nil && len(previous.GetVisitTimes(userAgent)) > 0 {
+			s.windows.SetHostWindows(host)
+		}
+		return
+	}
+
+	windows := make([]*limits.TimeWindow, len(visitTimes))
+	for i, visitTime := range visitTimes {
+		windows[i] = limits.NewTimeWindow(visitTime.Start, visitTime.End, time.UTC)
+	}
+	s.windows.SetHostWindows(host, windows...)
+}
+
+// IgnorePageRobotRules ignores the robots directives of pages.
+// Implementation of PageRobotLimitFunction.
+func IgnorePageRobotRules(s *Spider, res *request.Response) error {
+	return nil
+}
+
+// FollowPageRobotRules parses the robots directives of a page that apply to the spider's user agent.
+// HTML documents are parsed to find <meta> directives, the body can still be read afterwards.
+// Implementation of PageRobotLimitFunction.
+func FollowPageRobotRules(s *Spider, res *request.Response) error {
+	var doc *goquery.Document
+	if res.ContentKind() == request.ContentHTML {
+		var err error
+		doc, err = res.Parse()
+		if err != nil {
+			return err
+		}
+	}
+	res.PageRules = robots.NewPageRules(s.UserAgent(res.Request), res.Header, doc)
 	return nil
 }
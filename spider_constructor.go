@@ -3,13 +3,18 @@ package wander
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/KillianMeersman/wander/limits"
 	"github.com/KillianMeersman/wander/limits/robots"
 	"github.com/KillianMeersman/wander/request"
+	"github.com/KillianMeersman/wander/structured"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
 )
 
 // NewSpider instantiates a new spider.
@@ -20,17 +25,28 @@ func NewSpider(options ...SpiderConstructorOption) (*Spider, error) {
 		UserAgent: func(req *request.Request) string {
 			return "wander<https://github.com/KillianMeersman/wander>"
 		},
-		RobotExclusionFunction: FollowRobotRules,
-		DefaultWaitTime:        30 * time.Second,
-		MaxWaitTime:            1 * time.Hour,
-		IgnoreTimeouts:         false,
+		RobotExclusionFunction:     FollowRobotRules,
+		PageRobotExclusionFunction: FollowPageRobotRules,
+		DefaultWaitTime:            30 * time.Second,
+		MaxWaitTime:                1 * time.Hour,
+		IgnoreTimeouts:             false,
+		RobotRetryTime:             10 * time.Minute,
+		SitemapScheme:              "https",
+		SitemapPriority:            SitemapPriority,
+		MaxRedirects:               10,
+		MaxBodySize:                DefaultMaxBodySize,
+		ContentTypeBodySizes:       make(map[string]int64),
+		Clock:                      time.Now,
 	}
 
+	throttle := limits.NewThrottleCollection(nil)
 	spider := &Spider{
 		SpiderState:      SpiderState{},
 		SpiderParameters: parameters,
 		AllowedDomains:   make([]string, 0),
 		limits:           make(map[string]limits.RequestFilter),
+		throttle:         &throttle,
+		windows:          limits.NewCrawlWindows(),
 
 		ingestorN: 1,
 
@@ -39,11 +55,30 @@ func NewSpider(options ...SpiderConstructorOption) (*Spider, error) {
 		ingestorWg: &sync.WaitGroup{},
 		lock:       lock,
 
-		requestFunc:      func(req *request.Request) *request.Request { return req },
-		responseFunc:     func(res *request.Response) {},
-		errorFunc:        func(err error) {},
-		selectors:        make(map[string]func(*request.Response, *goquery.Selection)),
-		pipelineDoneFunc: func() {},
+		requestFunc:         func(req *request.Request) *request.Request { return req },
+		responseFunc:        func(res *request.Response) {},
+		errorFunc:           func(err error) {},
+		selectors:           make(map[string]func(*request.Response, *goquery.Selection)),
+		xpathSelectors:      make(map[string]func(*request.Response, *html.Node)),
+		jsonSelectors:       make(map[string]func(*request.Response, *jsonquery.Node)),
+		xmlSelectors:        make(map[string]func(*request.Response, *xmlquery.Node)),
+		structuredSelectors: make(map[string]func(*request.Response, *structured.Item)),
+		binaryFunc:          func(res *request.Response) {},
+		pipelineDoneFunc:    func() {},
+		backoffStartFunc:    func(host string, waitTime time.Duration) {},
+		backoffEndFunc:      func(host string) {},
+
+		backoffs:    make(map[string]*hostBackoff),
+		backoffLock: &sync.Mutex{},
+
+		sessions:    make(map[string]*sessionAuth),
+		sessionLock: &sync.Mutex{},
+		retried:     make(map[string]struct{}),
+
+		robotDownloads:    make(map[string]*robotDownload),
+		robotDownloadLock: &sync.Mutex{},
+		robotWindows:      make(map[string]*robots.RobotFile),
+		robotWindowsLock:  &sync.Mutex{},
 	}
 
 	for _, option := range options {
@@ -123,18 +158,42 @@ func Cache(cache request.Cache) SpiderConstructorOption {
 	}
 }
 
+// CookieJars sets the cookie jars, enabling cookies.
+// Allows cookies to be persisted and shared between spiders.
+func CookieJars(jars request.CookieJars) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.Cookies = jars
+		return nil
+	}
+}
+
+// Cookies enables cookies using in-memory cookie jars.
+func Cookies() SpiderConstructorOption {
+	return CookieJars(request.NewCookieJars())
+}
+
+// Authentication is a constructor function for SetAuthentication.
+func Authentication(authenticate AuthenticateFunction, loggedOut LoggedOutFunction) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.SetAuthentication(authenticate, loggedOut)
+		return nil
+	}
+}
+
 // RobotLimits sets the robot exclusion cache.
-func RobotLimits(limits *robots.RobotRules) SpiderConstructorOption {
+func RobotLimits(limits robots.RobotRules) SpiderConstructorOption {
 	return func(s *Spider) error {
 		s.RobotLimits = limits
 		return nil
 	}
 }
 
-// IgnoreRobots sets the spider's RobotExclusionFunction to IgnoreRobotRules, ignoring robots.txt.
+// IgnoreRobots sets the spider's RobotExclusionFunction to IgnoreRobotRules and PageRobotExclusionFunction to IgnorePageRobotRules,
+// ignoring robots.txt and the robots directives of pages.
 func IgnoreRobots() SpiderConstructorOption {
 	return func(s *Spider) error {
 		s.RobotExclusionFunction = IgnoreRobotRules
+		s.PageRobotExclusionFunction = IgnorePageRobotRules
 		return nil
 	}
 }
@@ -154,3 +213,61 @@ func Throttle(defaultThrottle *limits.DefaultThrottle, domainThrottles ...*limit
 		return nil
 	}
 }
+
+// HostThrottle is a constructor function for SetHostThrottle.
+// Allows throttles to be shared between spiders.
+func HostThrottle(throttle limits.HostThrottle) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.SetHostThrottle(throttle)
+		return nil
+	}
+}
+
+// CrawlWindows is a constructor function for SetCrawlWindows.
+func CrawlWindows(host string, windows ...*limits.TimeWindow) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.SetCrawlWindows(host, windows...)
+		return nil
+	}
+}
+
+// VisitSitemaps makes the spider visit the sitemaps of all allowed domains when it starts, see Spider.VisitSitemaps.
+// Reading the sitemaps stops when the spider is stopped.
+func VisitSitemaps() SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.seedSitemaps = true
+		return nil
+	}
+}
+
+// MaxRedirects sets the maximum amount of redirects followed per request, redirects are not followed if 0.
+func MaxRedirects(n int) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.MaxRedirects = n
+		return nil
+	}
+}
+
+// MaxBodySize sets the maximum size of response bodies in bytes, bodies are not limited if 0 or less.
+func MaxBodySize(limit int64) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.MaxBodySize = limit
+		return nil
+	}
+}
+
+// ContentTypeBodySize sets the maximum body size for a media type, e.a. "application/pdf" or "video/*".
+func ContentTypeBodySize(mediaType string, limit int64) SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.ContentTypeBodySizes[strings.ToLower(mediaType)] = limit
+		return nil
+	}
+}
+
+// TruncateBodies cuts response bodies off at the maximum body size instead of aborting the response.
+func TruncateBodies() SpiderConstructorOption {
+	return func(s *Spider) error {
+		s.TruncateBodies = true
+		return nil
+	}
+}
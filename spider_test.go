@@ -3,20 +3,32 @@ package wander_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/KillianMeersman/wander"
 	"github.com/KillianMeersman/wander/limits"
+	"github.com/KillianMeersman/wander/limits/robots"
 	"github.com/KillianMeersman/wander/request"
+	"github.com/KillianMeersman/wander/structured"
 	"github.com/KillianMeersman/wander/util"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/brotli"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/jsonquery"
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
 )
 
 type route struct {
@@ -87,8 +99,138 @@ func randomLinkServer() *http.Server {
 		w.Write(msg)
 	}
 
+	busy := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+
+	sitemap := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<urlset>
+		<url><loc>http://localhost:8080/test/sitemap-low</loc><priority>0.2</priority></url>
+		<url><loc>http://localhost:8080/test/sitemap-high</loc><priority>0.8</priority></url>
+		<url><loc>http://example.com/test/sitemap-forbidden</loc></url>
+		</urlset>`))
+	}
+
+	nofollow := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta name="robots" content="noindex, nofollow"></head>
+		<body><a href="/test/nofollow">link</a></body></html>`))
+	}
+
+	cookie := func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "wander", Path: "/"})
+	}
+
+	logins := int32(0)
+	loginPage := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><form action="/login" method="post">
+		<input type="hidden" name="token" value="wander">
+		<input type="text" name="username">
+		</form></body></html>`))
+	}
+	login := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.PostFormValue("token") != "wander" || r.PostFormValue("username") != "alice" {
+			http.Error(w, "invalid login", http.StatusForbidden)
+			return
+		}
+		n := atomic.AddInt32(&logins, 1)
+		http.SetCookie(w, &http.Cookie{Name: "auth", Value: fmt.Sprint(n), Path: "/"})
+	}
+	private := func(w http.ResponseWriter, r *http.Request) {
+		// the first login expires immediately
+		if c, err := r.Cookie("auth"); err != nil || c.Value == "1" {
+			http.Redirect(w, r, "/login-page", http.StatusFound)
+			return
+		}
+		w.Write([]byte("private"))
+	}
+
+	redirect := func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/redirect/%d", &n)
+		if n <= 0 {
+			w.Write([]byte("redirected"))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/redirect/%d", n-1), http.StatusFound)
+	}
+	redirectExternal := func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com/", http.StatusMovedPermanently)
+	}
+	redirectDisallowed := func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/test1", http.StatusFound)
+	}
+
+	large := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(strings.Repeat("wander", 1000)))
+	}
+
+	api := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items": [{"name": "a", "price": 5}, {"name": "b", "price": 15}]}`))
+	}
+	feed := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss><channel><item><title>wander</title></item></channel></rss>`))
+	}
+	pdf := func(w http.ResponseWriter, r *http.Request) {
+		// no Content-Type header, the type is sniffed
+		w.Header()["Content-Type"] = nil
+		w.Write([]byte("%PDF-1.5 wander"))
+	}
+
+	compressed := func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "br") {
+			http.Error(w, "brotli not accepted", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "br")
+		writer := brotli.NewWriter(w)
+		writer.Write([]byte(strings.Repeat("wander", 1000)))
+		writer.Close()
+	}
+
+	product := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><script type="application/ld+json">
+			{"@context": "https://schema.org", "@type": "Product", "name": "wander", "offers": {"@type": "Offer", "price": "9.99"}}
+		</script></head><body>
+			<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">spider</span></div>
+		</body></html>`))
+	}
+
+	invalidJSON := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items": [`))
+	}
+
 	handler := &regexpHandler{}
 	handler.HandleFunc(regexp.MustCompile(`(?m)^\/robots\.txt$`), robots)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/busy$`), busy)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/sitemap\.xml$`), sitemap)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/nofollow$`), nofollow)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/cookie$`), cookie)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/login-page$`), loginPage)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/login$`), login)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/private$`), private)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/redirect\/\d+$`), redirect)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/redirect-external$`), redirectExternal)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/redirect-disallowed$`), redirectDisallowed)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/large$`), large)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/api\.json$`), api)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/feed\.xml$`), feed)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/file\.pdf$`), pdf)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/compressed$`), compressed)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/product$`), product)
+	handler.HandleFunc(regexp.MustCompile(`(?m)^\/invalid\.json$`), invalidJSON)
 	handler.HandleFunc(regexp.MustCompile(`(?m)^\/test.*`), randomLinks)
 
 	serv := &http.Server{
@@ -101,7 +243,12 @@ func randomLinkServer() *http.Server {
 func TestMain(m *testing.M) {
 	ctx := context.Background()
 	serv := randomLinkServer()
-	go serv.ListenAndServe()
+	// listen before running the tests so early requests aren't refused
+	listener, err := net.Listen("tcp", serv.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go serv.Serve(listener)
 	defer serv.Shutdown(ctx)
 	os.Exit(m.Run())
 }
@@ -133,6 +280,758 @@ func TestSyncVisit(t *testing.T) {
 	}
 }
 
+func TestBackoff(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080", "127.0.0.1:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan time.Duration, 1)
+	ended := make(chan string, 1)
+	spid.OnBackoffStart(func(host string, waitTime time.Duration) {
+		started <- waitTime
+	})
+	spid.OnBackoffEnd(func(host string) {
+		ended <- host
+	})
+
+	res, err := spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/busy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spid.CheckResponseStatus(res); err != nil {
+		t.Fatal(err)
+	}
+	if waitTime := <-started; waitTime != time.Second {
+		t.Fatalf("expected a backoff of 1s, got %s", waitTime)
+	}
+
+	// other hosts should not be affected by the backoff
+	start := time.Now()
+	_, err = spid.VisitNow(&url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("backoff applied to other host")
+	}
+
+	if host := <-ended; host != "localhost:8080" {
+		t.Fatalf("backoff ended for wrong host %s", host)
+	}
+}
+
+func TestBackoffRetryAfterDate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "Sat, 20 Jun 2020 00:00:02 GMT")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains(u.Host),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Clock = func() time.Time { return time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC) }
+	started := make(chan time.Duration, 1)
+	spid.OnBackoffStart(func(host string, waitTime time.Duration) {
+		started <- waitTime
+	})
+
+	res, err := spid.VisitNow(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spid.CheckResponseStatus(res); err != nil {
+		t.Fatal(err)
+	}
+	if waitTime := <-started; waitTime != 2*time.Second {
+		t.Fatalf("expected a backoff of 2s, got %s", waitTime)
+	}
+}
+
+func TestCrawlWindowDeferral(t *testing.T) {
+	// a window opening in two hours
+	now := time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC)
+	window := limits.NewTimeWindow(2*time.Hour, 3*time.Hour, time.UTC)
+
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+		wander.CrawlWindows("localhost:8080", window),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Clock = func() time.Time { return now }
+
+	requests := make(chan *request.Request, 1)
+	spid.OnRequest(func(req *request.Request) *request.Request {
+		requests <- req
+		return req
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	select {
+	case req := <-requests:
+		t.Fatalf("request to %s made outside of crawl window", req.URL)
+	case <-time.After(100 * time.Millisecond):
+	}
+	state := spid.Stop(context.Background())
+
+	count, err := state.Queue.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("deferred request not in queue after stopping, queue size is %d", count)
+	}
+}
+
+func TestRobotVisitTimesReloaded(t *testing.T) {
+	var lock sync.Mutex
+	robotsTxt := "User-agent: *\nVisit-time: 0200-0300\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte(robotsTxt))
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spid, err := wander.NewSpider(wander.AllowedDomains(u.Host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Clock = func() time.Time { return time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC) }
+	requests := make(chan *request.Request, 1)
+	spid.OnRequest(func(req *request.Request) *request.Request {
+		requests <- req
+		return req
+	})
+
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wander.FollowRobotRules(spid, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new robots.txt no longer declares a Visit-time
+	lock.Lock()
+	robotsTxt = "User-agent: *\nDisallow:\n"
+	lock.Unlock()
+	err = spid.RobotLimits.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = spid.Visit(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+	select {
+	case <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("crawl windows of the old robots.txt still applied")
+	}
+}
+
+func TestRobotsSingleDownload(t *testing.T) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer server.Close()
+
+	spid, err := wander.NewSpider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(server.URL + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := wander.FollowRobotRules(spid, req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if downloads != 1 {
+		t.Fatalf("robots.txt downloaded %d times, expected once", downloads)
+	}
+}
+
+func TestVisitSitemaps(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.SitemapScheme = "http"
+	errs := make(chan error, 10)
+	spid.OnError(func(err error) {
+		errs <- err
+	})
+
+	<-spid.VisitSitemaps(context.Background())
+
+	count, err := spid.Queue.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 sitemap locations in queue, got %d", count)
+	}
+	// locations outside the allowed domains are reported
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errs))
+	}
+
+	res := <-spid.Queue.Dequeue()
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if res.Request.URL.Path != "/test/sitemap-high" || res.Priority != 800 {
+		t.Fatalf("expected /test/sitemap-high with priority 800, got %s with priority %d", res.Request.URL.Path, res.Priority)
+	}
+}
+
+func TestPageNoFollow(t *testing.T) {
+	spid, err := wander.NewSpider(wander.AllowedDomains("localhost:8080"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan error, 1)
+	spid.OnHTML("a[href]", func(res *request.Response, el *goquery.Selection) {
+		if !res.PageRules.NoIndex {
+			t.Error("noindex directive not set")
+		}
+		link, _ := el.Attr("href")
+		url, err := url.Parse(link)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		results <- spid.Follow(url, res, 1)
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/nofollow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	select {
+	case err := <-results:
+		if _, ok := err.(robots.PageNoFollow); !ok {
+			t.Fatalf("expected PageNoFollow, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("page not visited")
+	}
+}
+
+func TestCookies(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.Cookies(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/cookie"}
+	for i := 0; i < 2; i++ {
+		res, err := spid.VisitNow(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && string(body) != "wander" {
+			t.Fatalf("cookie not sent on second request, got %q", body)
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+		wander.Cookies(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authentications := int32(0)
+	authenticate := func(spid *wander.Spider, session string) error {
+		atomic.AddInt32(&authentications, 1)
+		req, err := request.NewRequest(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/login-page"}, nil)
+		if err != nil {
+			return err
+		}
+		req.Session = session
+		res, err := spid.RequestNow(req)
+		if err != nil {
+			return err
+		}
+		form, err := res.Form("form")
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		form.Set("username", "alice")
+		req, err = form.Request()
+		if err != nil {
+			return err
+		}
+		res, err = spid.RequestNow(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		return spid.CheckResponseStatus(res)
+	}
+	loggedOut := func(res *request.Response) bool {
+		return res.Response.Request.URL.Path == "/login-page"
+	}
+	spid.SetAuthentication(authenticate, loggedOut)
+
+	bodies := make(chan string, 1)
+	spid.OnResponse(func(res *request.Response) {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		bodies <- string(body)
+	})
+	spid.OnError(func(err error) {
+		t.Error(err)
+	})
+
+	err = spid.VisitSession(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/private"}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	select {
+	case body := <-bodies:
+		if body != "private" {
+			t.Fatalf("expected private page, got %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("private page not visited")
+	}
+	// the first login expired, the session was logged in again
+	if n := atomic.LoadInt32(&authentications); n != 2 {
+		t.Fatalf("expected 2 authentications, got %d", n)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.UserAgent(func(req *request.Request) string {
+			return "Wander/0.1"
+		}),
+		wander.MaxRedirects(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect/2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.FinalURL().Path != "/redirect/0" || res.Request.URL.Path != "/redirect/2" {
+		t.Fatalf("expected final url /redirect/0, got %s", res.FinalURL())
+	}
+	if len(res.Redirects) != 2 || res.Redirects[0].URL.Path != "/redirect/2" || res.Redirects[1].URL.Path != "/redirect/1" {
+		t.Fatalf("unexpected redirect chain %v", res.Redirects)
+	}
+	if res.Redirects[0].StatusCode != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, res.Redirects[0].StatusCode)
+	}
+
+	_, err = spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect/3"})
+	if _, ok := err.(wander.TooManyRedirects); !ok {
+		t.Fatalf("expected TooManyRedirects, got %v", err)
+	}
+	_, err = spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect-external"})
+	if _, ok := err.(limits.ForbiddenDomain); !ok {
+		t.Fatalf("expected ForbiddenDomain, got %v", err)
+	}
+	_, err = spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect-disallowed"})
+	if _, ok := err.(robots.RobotDenied); !ok {
+		t.Fatalf("expected RobotDenied, got %v", err)
+	}
+
+	spid.MaxRedirects = 0
+	res, err = spid.VisitNow(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound || len(res.Redirects) != 0 {
+		t.Fatalf("expected unfollowed redirect, got status %d", res.StatusCode)
+	}
+}
+
+func TestRedirectsVisited(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := make(chan *request.Response, 2)
+	spid.OnResponse(func(res *request.Response) {
+		responses <- res
+	})
+	spid.OnError(func(err error) {
+		t.Error(err)
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/redirect/2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	select {
+	case res := <-responses:
+		if res.FinalURL().Path != "/redirect/0" {
+			t.Fatalf("expected final url /redirect/0, got %s", res.FinalURL())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("page not visited")
+	}
+
+	for _, path := range []string{"/redirect/1", "/redirect/0"} {
+		err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: path})
+		if _, ok := err.(wander.AlreadyVisited); !ok {
+			t.Fatalf("expected %s to be visited, got %v", path, err)
+		}
+	}
+}
+
+func TestBodySizeLimit(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.MaxBodySize(100),
+		wander.ContentTypeBodySize("text/*", 1000),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit := spid.BodySizeLimit("text/html; charset=utf-8"); limit != 1000 {
+		t.Fatalf("expected limit 1000 for text/html, got %d", limit)
+	}
+	if limit := spid.BodySizeLimit("application/pdf"); limit != 100 {
+		t.Fatalf("expected limit 100 for application/pdf, got %d", limit)
+	}
+
+	u := &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/large"}
+	// the body is chunked, the limit is exceeded while reading
+	res, err := spid.VisitNow(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if e, ok := err.(request.BodyTooLarge); !ok || e.Limit != 1000 {
+		t.Fatalf("expected BodyTooLarge, got %v", err)
+	}
+
+	spid.TruncateBodies = true
+	res, err = spid.VisitNow(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 1000 || !res.Truncated {
+		t.Fatalf("expected body truncated to 1000 bytes, got %d", len(body))
+	}
+}
+
+func TestContentDispatch(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan string, 10)
+	spid.OnHTML("a", func(res *request.Response, el *goquery.Selection) {
+		t.Errorf("HTML selector run for %s", res.Request.URL)
+	})
+	spid.OnJSON("//items/*[price>10]/name", func(res *request.Response, node *jsonquery.Node) {
+		results <- "json " + node.InnerText()
+	})
+	spid.OnXML("//item/title", func(res *request.Response, node *xmlquery.Node) {
+		results <- "xml " + node.InnerText()
+	})
+	spid.OnBinary(func(res *request.Response) {
+		body := &strings.Builder{}
+		if _, err := res.StreamBody(body); err != nil {
+			t.Error(err)
+		}
+		results <- res.ContentType() + " " + body.String()
+	})
+	spid.OnError(func(err error) {
+		t.Error(err)
+	})
+
+	for _, path := range []string{"/api.json", "/feed.xml", "/file.pdf"} {
+		err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	expected := map[string]bool{
+		"json b":                          true,
+		"xml wander":                      true,
+		"application/pdf %PDF-1.5 wander": true,
+	}
+	for len(expected) > 0 {
+		select {
+		case result := <-results:
+			if !expected[result] {
+				t.Fatalf("unexpected result %q", result)
+			}
+			delete(expected, result)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("callbacks not called: %v", expected)
+		}
+	}
+}
+
+func TestPipelineFinishedAfterDispatchError(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	finished := make(chan struct{}, 1)
+	spid.OnJSON("//items", func(res *request.Response, node *jsonquery.Node) {
+		t.Error("selector run for invalid JSON")
+	})
+	spid.OnError(func(err error) {
+		errs <- err
+	})
+	spid.OnPipelineFinished(func() {
+		finished <- struct{}{}
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/invalid.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parse error not reported")
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline not finished after dispatch error")
+	}
+}
+
+func TestXPath(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := make(chan *goquery.Document, 2)
+	spid.OnHTML("body", func(res *request.Response, el *goquery.Selection) {
+		documents <- res.Document
+	})
+	spid.OnXPath(`//a[text()="test2"]`, func(res *request.Response, node *html.Node) {
+		if href := htmlquery.SelectAttr(node, "href"); !strings.HasPrefix(href, "/test/") {
+			t.Errorf("unexpected link %s", href)
+		}
+		documents <- res.Document
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/test/xpath"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	var first *goquery.Document
+	for i := 0; i < 2; i++ {
+		select {
+		case doc := <-documents:
+			if doc == nil || (first != nil && doc != first) {
+				t.Fatal("document parsed more than once")
+			}
+			first = doc
+		case <-time.After(5 * time.Second):
+			t.Fatal("selectors not called")
+		}
+	}
+}
+
+func TestDecompression(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.MaxBodySize(100000),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/compressed"}
+	res, err := spid.VisitNow(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != strings.Repeat("wander", 1000) {
+		t.Fatalf("body not decoded: %q", body[:20])
+	}
+	if res.DecodedSize != 6000 || res.WireSize >= res.DecodedSize {
+		t.Fatalf("unexpected wire size %d and decoded size %d", res.WireSize, res.DecodedSize)
+	}
+
+	// limits apply to the decoded body
+	spid.MaxBodySize = 1000
+	res, err = spid.VisitNow(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if _, ok := err.(request.BodyTooLarge); !ok {
+		t.Fatalf("expected BodyTooLarge, got %v", err)
+	}
+}
+
+func TestStructuredData(t *testing.T) {
+	spid, err := wander.NewSpider(
+		wander.AllowedDomains("localhost:8080"),
+		wander.IgnoreRobots(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan string, 10)
+	spid.OnStructuredData("Product", func(res *request.Response, item *structured.Item) {
+		results <- item.String("name")
+	})
+	spid.OnStructuredData("https://schema.org/Offer", func(res *request.Response, item *structured.Item) {
+		results <- item.String("price")
+	})
+	spid.OnError(func(err error) {
+		t.Error(err)
+	})
+
+	err = spid.Visit(&url.URL{Scheme: "http", Host: "localhost:8080", Path: "/product"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spid.Start()
+	defer spid.Stop(context.Background())
+
+	expected := map[string]bool{
+		"wander": true,
+		"spider": true,
+		"9.99":   true,
+	}
+	for len(expected) > 0 {
+		select {
+		case result := <-results:
+			if !expected[result] {
+				t.Fatalf("unexpected result %q", result)
+			}
+			delete(expected, result)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("callbacks not called: %v", expected)
+		}
+	}
+}
+
 func BenchmarkSpiderWithHeapQueue(b *testing.B) {
 	queue := request.NewRequestHeap(10000)
 	benchmarkSpider(b, queue)
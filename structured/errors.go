@@ -0,0 +1,16 @@
+package structured
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// InvalidJSONLD indicates a JSON-LD block could not be parsed.
+type InvalidJSONLD struct {
+	URL url.URL
+	Err error
+}
+
+func (e InvalidJSONLD) Error() string {
+	return fmt.Sprintf("invalid JSON-LD in %s: %s", e.URL.String(), e.Err)
+}
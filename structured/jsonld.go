@@ -0,0 +1,125 @@
+package structured
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractJSONLD parses the <script type="application/ld+json"> blocks of a document.
+// Returns InvalidJSONLD for the first block that could not be parsed, the other blocks are still returned.
+func extractJSONLD(doc *goquery.Document) ([]*Item, error) {
+	items := make([]*Item, 0)
+	var firstErr error
+	doc.Find("script[type]").Each(func(_ int, script *goquery.Selection) {
+		scriptType := strings.ToLower(strings.TrimSpace(script.AttrOr("type", "")))
+		if !strings.HasPrefix(scriptType, "application/ld+json") {
+			return
+		}
+		blockItems, err := parseJSONLD([]byte(script.Text()))
+		if err != nil {
+			if firstErr == nil {
+				location := url.URL{}
+				if doc.Url != nil {
+					location = *doc.Url
+				}
+				firstErr = InvalidJSONLD{URL: location, Err: err}
+			}
+			return
+		}
+		items = append(items, blockItems...)
+	})
+	return items, firstErr
+}
+
+// parseJSONLD parses a JSON-LD document, which may hold a single node, an array of nodes or a @graph.
+func parseJSONLD(body []byte) ([]*Item, error) {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	items := make([]*Item, 0)
+	var collect func(node interface{})
+	collect = func(node interface{}) {
+		switch n := node.(type) {
+		case []interface{}:
+			for _, child := range n {
+				collect(child)
+			}
+		case map[string]interface{}:
+			if graph, ok := n["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			items = append(items, jsonLDItem(n, 0))
+		}
+	}
+	collect(document)
+	return items, nil
+}
+
+// jsonLDItem converts a JSON-LD node object to an item.
+func jsonLDItem(node map[string]interface{}, depth int) *Item {
+	item := newItem()
+	for key, value := range node {
+		switch key {
+		case "@type":
+			item.Types = append(item.Types, jsonLDStrings(value)...)
+		case "@id":
+			item.ID, _ = value.(string)
+		default:
+			if strings.HasPrefix(key, "@") {
+				// keywords such as @context don't describe the item
+				continue
+			}
+			for _, v := range jsonLDValues(value, depth) {
+				item.add(key, v)
+			}
+		}
+	}
+	return item
+}
+
+// jsonLDValues converts a JSON-LD property value to item property values, arrays hold multiple values.
+func jsonLDValues(value interface{}, depth int) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			values = append(values, jsonLDValues(child, depth)...)
+		}
+		return values
+	case map[string]interface{}:
+		if literal, ok := v["@value"]; ok {
+			return jsonLDValues(literal, depth)
+		}
+		if depth >= maxDepth {
+			return nil
+		}
+		return []interface{}{jsonLDItem(v, depth+1)}
+	case nil:
+		return nil
+	default:
+		return []interface{}{v}
+	}
+}
+
+func jsonLDStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, child := range v {
+			if s, ok := child.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
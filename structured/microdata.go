@@ -0,0 +1,188 @@
+package structured
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// syntax describes how items and their properties are marked up, Microdata and RDFa are handled the same way.
+type syntax struct {
+	// property is the attribute naming the properties of an element
+	property string
+	// isItem returns true if an element starts a new item, its descendants describe that item
+	isItem func(el *goquery.Selection) bool
+	// item creates an item from an element, without its properties
+	item func(el *goquery.Selection, base *url.URL) *Item
+	// value returns the value of a property element that isn't an item
+	value func(el *goquery.Selection, base *url.URL) string
+}
+
+// reader reads the items of a document.
+type reader struct {
+	syntax
+	doc *goquery.Document
+	// ids indexes the elements of the document by id for Microdata itemref, built on first use
+	ids map[string]*goquery.Selection
+	// read holds the item elements read for the current top-level item.
+	// Every element is read once, so reference cycles and repeated references can't make the work grow exponentially.
+	read map[*html.Node]bool
+}
+
+func (s syntax) reader(doc *goquery.Document) *reader {
+	return &reader{
+		syntax: s,
+		doc:    doc,
+	}
+}
+
+// readTopLevel reads a top-level item element and its properties.
+func (r *reader) readTopLevel(el *goquery.Selection) *Item {
+	r.read = make(map[*html.Node]bool)
+	return r.readItem(el, 0)
+}
+
+// readItem reads an item element and its properties, properties that are items are read recursively.
+// Items that were already read for the current top-level item, e.a. an ancestor referenced by itemref, are skipped.
+func (r *reader) readItem(el *goquery.Selection, depth int) *Item {
+	r.read[el.Get(0)] = true
+	item := r.item(el, r.doc.Url)
+
+	for _, prop := range r.propertyElements(el) {
+		var value interface{}
+		if r.isItem(prop) {
+			if depth >= maxDepth || r.read[prop.Get(0)] {
+				continue
+			}
+			value = r.readItem(prop, depth+1)
+		} else {
+			value = r.value(prop, r.doc.Url)
+		}
+		for _, name := range strings.Fields(prop.AttrOr(r.property, "")) {
+			item.add(name, value)
+		}
+	}
+	return item
+}
+
+// propertyElements returns the property elements of an item element, including those referenced by Microdata itemref.
+// Every element is returned once, even if it is referenced multiple times.
+func (r *reader) propertyElements(el *goquery.Selection) []*goquery.Selection {
+	props := make([]*goquery.Selection, 0)
+	seen := make(map[*html.Node]bool)
+	add := func(prop *goquery.Selection) {
+		if !seen[prop.Get(0)] {
+			seen[prop.Get(0)] = true
+			props = append(props, prop)
+		}
+	}
+
+	r.properties(el.Children(), add)
+	for _, id := range strings.Fields(el.AttrOr("itemref", "")) {
+		ref, ok := r.elementByID(id)
+		if !ok {
+			continue
+		}
+		if _, ok := ref.Attr(r.property); ok {
+			add(ref)
+		}
+		if !r.isItem(ref) {
+			r.properties(ref.Children(), add)
+		}
+	}
+	return props
+}
+
+// elementByID returns the first element of the document with the id.
+func (r *reader) elementByID(id string) (*goquery.Selection, bool) {
+	if r.ids == nil {
+		r.ids = make(map[string]*goquery.Selection)
+		r.doc.Find("[id]").Each(func(_ int, el *goquery.Selection) {
+			key := el.AttrOr("id", "")
+			if _, ok := r.ids[key]; !ok {
+				r.ids[key] = el
+			}
+		})
+	}
+	el, ok := r.ids[id]
+	return el, ok
+}
+
+// properties calls fn for each property element in the selection and its descendants, not descending into nested items.
+func (s syntax) properties(sel *goquery.Selection, fn func(prop *goquery.Selection)) {
+	sel.Each(func(_ int, el *goquery.Selection) {
+		if _, ok := el.Attr(s.property); ok {
+			fn(el)
+		}
+		if !s.isItem(el) {
+			s.properties(el.Children(), fn)
+		}
+	})
+}
+
+var microdata = syntax{
+	property: "itemprop",
+	isItem: func(el *goquery.Selection) bool {
+		_, ok := el.Attr("itemscope")
+		return ok
+	},
+	item: func(el *goquery.Selection, base *url.URL) *Item {
+		item := newItem()
+		item.Types = strings.Fields(el.AttrOr("itemtype", ""))
+		item.ID = resolve(base, el.AttrOr("itemid", ""))
+		return item
+	},
+	value: func(el *goquery.Selection, base *url.URL) string {
+		return elementValue(el, base)
+	},
+}
+
+// extractMicrodata returns the top-level Microdata items of a document, items that aren't a property of another item.
+func extractMicrodata(doc *goquery.Document) []*Item {
+	items := make([]*Item, 0)
+	reader := microdata.reader(doc)
+	doc.Find("[itemscope]").Each(func(_ int, el *goquery.Selection) {
+		if _, ok := el.Attr("itemprop"); ok {
+			return
+		}
+		items = append(items, reader.readTopLevel(el))
+	})
+	return items
+}
+
+// elementValue returns the value of a property element according to the Microdata spec.
+// Urls are resolved against the base url.
+func elementValue(el *goquery.Selection, base *url.URL) string {
+	switch goquery.NodeName(el) {
+	case "meta":
+		return el.AttrOr("content", "")
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolve(base, el.AttrOr("src", ""))
+	case "a", "area", "link":
+		return resolve(base, el.AttrOr("href", ""))
+	case "object":
+		return resolve(base, el.AttrOr("data", ""))
+	case "data", "meter":
+		return el.AttrOr("value", "")
+	case "time":
+		if datetime, ok := el.Attr("datetime"); ok {
+			return datetime
+		}
+	}
+	return strings.TrimSpace(el.Text())
+}
+
+// resolve resolves a reference against the base url, the reference is returned as is if it can't be resolved.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == nil || ref == "" {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
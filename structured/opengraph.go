@@ -0,0 +1,165 @@
+package structured
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// openGraphPrefixes are the OpenGraph namespaces collected in OpenGraph.Properties.
+var openGraphPrefixes = []string{"og:", "article:", "book:", "profile:", "music:", "video:"}
+
+// OpenGraphMedia is an image, video or audio file described by OpenGraph metadata.
+type OpenGraphMedia struct {
+	URL       string
+	SecureURL string
+	Type      string
+	Width     int
+	Height    int
+	Alt       string
+}
+
+// OpenGraph holds the OpenGraph metadata of a page.
+type OpenGraph struct {
+	Title       string
+	Type        string
+	URL         string
+	Description string
+	SiteName    string
+	Locale      string
+	Images      []OpenGraphMedia
+	Videos      []OpenGraphMedia
+	Audio       []OpenGraphMedia
+	// Properties holds all OpenGraph properties by name, including the namespaced properties of object types such as "article:published_time".
+	Properties map[string][]string
+}
+
+// extractOpenGraph reads the OpenGraph <meta property> tags of a document, returns nil if there are none.
+func extractOpenGraph(doc *goquery.Document) *OpenGraph {
+	var og *OpenGraph
+	doc.Find("meta[property][content]").Each(func(_ int, meta *goquery.Selection) {
+		property := strings.ToLower(strings.TrimSpace(meta.AttrOr("property", "")))
+		if !hasOpenGraphPrefix(property) {
+			return
+		}
+		if og == nil {
+			og = &OpenGraph{
+				Images:     make([]OpenGraphMedia, 0),
+				Videos:     make([]OpenGraphMedia, 0),
+				Audio:      make([]OpenGraphMedia, 0),
+				Properties: make(map[string][]string),
+			}
+		}
+		content := strings.TrimSpace(meta.AttrOr("content", ""))
+		og.Properties[property] = append(og.Properties[property], content)
+
+		switch property {
+		case "og:title":
+			og.Title = content
+		case "og:type":
+			og.Type = content
+		case "og:url":
+			og.URL = content
+		case "og:description":
+			og.Description = content
+		case "og:site_name":
+			og.SiteName = content
+		case "og:locale":
+			og.Locale = content
+		default:
+			for _, media := range []struct {
+				name  string
+				media *[]OpenGraphMedia
+			}{{"og:image", &og.Images}, {"og:video", &og.Videos}, {"og:audio", &og.Audio}} {
+				if property == media.name || strings.HasPrefix(property, media.name+":") {
+					setMediaProperty(media.media, strings.TrimPrefix(property[len(media.name):], ":"), content)
+				}
+			}
+		}
+	})
+	return og
+}
+
+// setMediaProperty sets a structured property of the last media file, og:image starts a new image, og:image:width sets its width.
+func setMediaProperty(media *[]OpenGraphMedia, property, content string) {
+	if property == "" || (property == "url" && len(*media) > 0 && (*media)[len(*media)-1].URL != "") {
+		*media = append(*media, OpenGraphMedia{URL: content})
+		return
+	}
+	if len(*media) == 0 {
+		*media = append(*media, OpenGraphMedia{})
+	}
+	last := &(*media)[len(*media)-1]
+	switch property {
+	case "url":
+		last.URL = content
+	case "secure_url":
+		last.SecureURL = content
+	case "type":
+		last.Type = content
+	case "width":
+		last.Width, _ = strconv.Atoi(content)
+	case "height":
+		last.Height, _ = strconv.Atoi(content)
+	case "alt":
+		last.Alt = content
+	}
+}
+
+func hasOpenGraphPrefix(property string) bool {
+	for _, prefix := range openGraphPrefixes {
+		if strings.HasPrefix(property, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TwitterCard holds the Twitter card metadata of a page.
+type TwitterCard struct {
+	Card        string
+	Site        string
+	Creator     string
+	Title       string
+	Description string
+	Image       string
+	ImageAlt    string
+	// Properties holds all twitter: properties by name.
+	Properties map[string]string
+}
+
+// extractTwitterCard reads the twitter: <meta> tags of a document, returns nil if there are none.
+// Both the name and the property attribute are supported, as both are used in practice.
+func extractTwitterCard(doc *goquery.Document) *TwitterCard {
+	var card *TwitterCard
+	doc.Find("meta[content]").Each(func(_ int, meta *goquery.Selection) {
+		name := strings.ToLower(strings.TrimSpace(meta.AttrOr("name", meta.AttrOr("property", ""))))
+		if !strings.HasPrefix(name, "twitter:") {
+			return
+		}
+		if card == nil {
+			card = &TwitterCard{Properties: make(map[string]string)}
+		}
+		content := strings.TrimSpace(meta.AttrOr("content", ""))
+		card.Properties[name] = content
+
+		switch name {
+		case "twitter:card":
+			card.Card = content
+		case "twitter:site":
+			card.Site = content
+		case "twitter:creator":
+			card.Creator = content
+		case "twitter:title":
+			card.Title = content
+		case "twitter:description":
+			card.Description = content
+		case "twitter:image", "twitter:image:src":
+			card.Image = content
+		case "twitter:image:alt":
+			card.ImageAlt = content
+		}
+	})
+	return card
+}
@@ -0,0 +1,63 @@
+package structured
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var rdfa = syntax{
+	property: "property",
+	isItem: func(el *goquery.Selection) bool {
+		_, ok := el.Attr("typeof")
+		return ok
+	},
+	item: func(el *goquery.Selection, base *url.URL) *Item {
+		item := newItem()
+		vocab := el.Closest("[vocab]").AttrOr("vocab", "")
+		for _, itemType := range strings.Fields(el.AttrOr("typeof", "")) {
+			// prefixed types and absolute iris don't use the vocabulary
+			if vocab != "" && !strings.Contains(itemType, ":") {
+				itemType = vocab + itemType
+			}
+			item.Types = append(item.Types, itemType)
+		}
+		if resource, ok := el.Attr("resource"); ok {
+			item.ID = resolve(base, resource)
+		} else if about, ok := el.Attr("about"); ok {
+			item.ID = resolve(base, about)
+		}
+		return item
+	},
+	value: func(el *goquery.Selection, base *url.URL) string {
+		if content, ok := el.Attr("content"); ok {
+			return content
+		}
+		if resource, ok := el.Attr("resource"); ok {
+			return resolve(base, resource)
+		}
+		return elementValue(el, base)
+	},
+}
+
+// extractRDFa returns the top-level RDFa (Lite) items of a document, and the properties outside of any item.
+func extractRDFa(doc *goquery.Document) ([]*Item, map[string][]string) {
+	items := make([]*Item, 0)
+	properties := make(map[string][]string)
+	reader := rdfa.reader(doc)
+	doc.Find("[typeof], [property]").Each(func(_ int, el *goquery.Selection) {
+		if el.ParentsFiltered("[typeof]").Length() > 0 {
+			return
+		}
+		if rdfa.isItem(el) {
+			items = append(items, reader.readTopLevel(el))
+			return
+		}
+		value := rdfa.value(el, doc.Url)
+		for _, name := range strings.Fields(el.AttrOr("property", "")) {
+			properties[name] = append(properties[name], value)
+		}
+	})
+	return items, properties
+}
@@ -0,0 +1,194 @@
+// Package structured extracts structured data from responses: JSON-LD, Microdata, RDFa, OpenGraph and Twitter card metadata.
+package structured
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KillianMeersman/wander/request"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxDepth limits the nesting of items, protecting against reference cycles.
+const maxDepth = 32
+
+// Item is an item described by JSON-LD, Microdata or RDFa, e.a. a schema.org Product.
+// Property values are strings, float64 or bool values (JSON-LD only), or nested items.
+type Item struct {
+	// Types holds the item's types as written in the document, e.a. "Product" or "https://schema.org/Product".
+	Types []string
+	// ID is the item's global identifier, the JSON-LD @id, Microdata itemid or RDFa resource.
+	ID         string
+	Properties map[string][]interface{}
+}
+
+func newItem() *Item {
+	return &Item{
+		Types:      make([]string, 0),
+		Properties: make(map[string][]interface{}),
+	}
+}
+
+// Is returns true if the item has the type.
+// Types are compared ignoring the schema.org vocabulary, so "Product" matches "https://schema.org/Product" and "schema:Product".
+func (i *Item) Is(itemType string) bool {
+	itemType = localType(itemType)
+	for _, t := range i.Types {
+		if localType(t) == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the first value of a property as a string, nested items are represented by their ID.
+// Returns an empty string if the item doesn't have the property.
+func (i *Item) String(property string) string {
+	values := i.Properties[property]
+	if len(values) < 1 {
+		return ""
+	}
+	return valueString(values[0])
+}
+
+// Strings returns the values of a property as strings, nested items are represented by their ID.
+func (i *Item) Strings(property string) []string {
+	values := make([]string, len(i.Properties[property]))
+	for j, value := range i.Properties[property] {
+		values[j] = valueString(value)
+	}
+	return values
+}
+
+// Items returns the nested items of a property.
+func (i *Item) Items(property string) []*Item {
+	items := make([]*Item, 0)
+	for _, value := range i.Properties[property] {
+		if item, ok := value.(*Item); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (i *Item) add(property string, value interface{}) {
+	i.Properties[property] = append(i.Properties[property], value)
+}
+
+// walk calls fn for the item and all items nested in it.
+func (i *Item) walk(fn func(*Item), depth int) {
+	if depth > maxDepth {
+		return
+	}
+	fn(i)
+	for _, values := range i.Properties {
+		for _, value := range values {
+			if item, ok := value.(*Item); ok {
+				item.walk(fn, depth+1)
+			}
+		}
+	}
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case *Item:
+		return v.ID
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// schemaPrefixes are the ways the schema.org vocabulary is written in front of type names.
+var schemaPrefixes = []string{"http://schema.org/", "https://schema.org/", "schema:"}
+
+func localType(itemType string) string {
+	for _, prefix := range schemaPrefixes {
+		if strings.HasPrefix(itemType, prefix) {
+			return itemType[len(prefix):]
+		}
+	}
+	return itemType
+}
+
+// Data holds the structured data of a page.
+type Data struct {
+	JSONLD    []*Item
+	Microdata []*Item
+	RDFa      []*Item
+	// RDFaProperties holds the RDFa properties outside of any typed item, e.a. OpenGraph meta tags.
+	RDFaProperties map[string][]string
+	// OpenGraph is nil if the page has no OpenGraph metadata.
+	OpenGraph *OpenGraph
+	// Twitter is nil if the page has no Twitter card metadata.
+	Twitter *TwitterCard
+}
+
+// Items returns the top-level JSON-LD, Microdata and RDFa items.
+func (d *Data) Items() []*Item {
+	items := make([]*Item, 0, len(d.JSONLD)+len(d.Microdata)+len(d.RDFa))
+	items = append(items, d.JSONLD...)
+	items = append(items, d.Microdata...)
+	return append(items, d.RDFa...)
+}
+
+// Find returns all items of a type, including nested items, see Item.Is.
+func (d *Data) Find(itemType string) []*Item {
+	found := make([]*Item, 0)
+	for _, item := range d.Items() {
+		item.walk(func(item *Item) {
+			if item.Is(itemType) {
+				found = append(found, item)
+			}
+		}, 0)
+	}
+	return found
+}
+
+// Extract extracts the structured data of a response.
+// HTML pages are searched for all supported formats, application/ld+json responses are read as a single JSON-LD document.
+// Invalid JSON-LD blocks are skipped, InvalidJSONLD is returned along with the rest of the data if there were any.
+func Extract(res *request.Response) (*Data, error) {
+	if res.ContentType() == "application/ld+json" {
+		body, err := res.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		data := newData()
+		data.JSONLD, err = parseJSONLD(body)
+		if err != nil {
+			err = InvalidJSONLD{URL: *res.Request.URL, Err: err}
+		}
+		return data, err
+	}
+
+	doc, err := res.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return ExtractDocument(doc)
+}
+
+// ExtractDocument extracts the structured data of a parsed HTML document.
+// Relative urls are resolved against the document's url.
+func ExtractDocument(doc *goquery.Document) (*Data, error) {
+	data := newData()
+	var err error
+	data.JSONLD, err = extractJSONLD(doc)
+	data.Microdata = extractMicrodata(doc)
+	data.RDFa, data.RDFaProperties = extractRDFa(doc)
+	data.OpenGraph = extractOpenGraph(doc)
+	data.Twitter = extractTwitterCard(doc)
+	return data, err
+}
+
+func newData() *Data {
+	return &Data{
+		JSONLD:         make([]*Item, 0),
+		Microdata:      make([]*Item, 0),
+		RDFa:           make([]*Item, 0),
+		RDFaProperties: make(map[string][]string),
+	}
+}
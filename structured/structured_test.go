@@ -0,0 +1,211 @@
+package structured_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KillianMeersman/wander/request"
+	"github.com/KillianMeersman/wander/structured"
+)
+
+const page = `<html>
+<head>
+	<meta property="og:title" content="Wander">
+	<meta property="og:type" content="article">
+	<meta property="og:image" content="https://example.com/one.png">
+	<meta property="og:image:width" content="300">
+	<meta property="og:image:alt" content="first">
+	<meta property="og:image" content="https://example.com/two.png">
+	<meta property="article:published_time" content="2020-01-01T00:00:00Z">
+	<meta name="twitter:card" content="summary_large_image">
+	<meta name="twitter:site" content="@wander">
+	<meta property="twitter:image" content="https://example.com/card.png">
+	<script type="application/ld+json">
+	{
+		"@context": "https://schema.org",
+		"@graph": [
+			{"@type": "Organization", "@id": "#org", "name": "Wander"},
+			{
+				"@type": ["Product", "Thing"],
+				"name": "Spider",
+				"sku": 123,
+				"inStock": true,
+				"offers": {"@type": "Offer", "price": {"@value": "9.99"}, "priceCurrency": "EUR"},
+				"image": ["a.png", "b.png"]
+			}
+		]
+	}
+	</script>
+	<script type="application/ld+json">{invalid</script>
+</head>
+<body>
+	<div itemscope itemtype="https://schema.org/Person" itemref="extra">
+		<span itemprop="name">Ada</span>
+		<a itemprop="url" href="/ada">profile</a>
+		<div itemprop="address" itemscope itemtype="https://schema.org/PostalAddress">
+			<span itemprop="addressLocality">London</span>
+		</div>
+		<time itemprop="birthDate" datetime="1815-12-10">December 10th</time>
+	</div>
+	<p id="extra"><span itemprop="jobTitle">Mathematician</span></p>
+
+	<div vocab="https://schema.org/" typeof="Event" resource="#launch">
+		<span property="name">Launch</span>
+		<div property="location" typeof="Place">
+			<span property="name">Brussels</span>
+		</div>
+		<meta property="startDate" content="2020-02-02">
+	</div>
+</body>
+</html>`
+
+func newResponse(t *testing.T, contentType, body string) *request.Response {
+	u, _ := url.Parse("https://example.com/page")
+	req, err := request.NewRequest(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	return request.NewResponse(req, http.Response{
+		StatusCode: 200,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	})
+}
+
+func TestExtract(t *testing.T) {
+	data, err := structured.Extract(newResponse(t, "text/html", page))
+	if _, ok := err.(structured.InvalidJSONLD); !ok {
+		t.Fatalf("expected InvalidJSONLD, got %v", err)
+	}
+	if data == nil {
+		t.Fatal("no data returned")
+	}
+
+	// JSON-LD
+	if len(data.JSONLD) != 2 {
+		t.Fatalf("expected 2 JSON-LD items, got %d", len(data.JSONLD))
+	}
+	products := data.Find("Product")
+	if len(products) != 1 {
+		t.Fatalf("expected 1 product, got %d", len(products))
+	}
+	product := products[0]
+	if product.String("name") != "Spider" || product.Properties["sku"][0] != float64(123) || product.Properties["inStock"][0] != true {
+		t.Fatalf("unexpected product %v", product.Properties)
+	}
+	if images := product.Strings("image"); len(images) != 2 {
+		t.Fatalf("expected 2 images, got %v", images)
+	}
+	offers := product.Items("offers")
+	if len(offers) != 1 || !offers[0].Is("Offer") || offers[0].String("price") != "9.99" {
+		t.Fatalf("unexpected offers %v", offers)
+	}
+	if orgs := data.Find("https://schema.org/Organization"); len(orgs) != 1 || orgs[0].ID != "#org" {
+		t.Fatalf("unexpected organizations %v", orgs)
+	}
+
+	// Microdata
+	if len(data.Microdata) != 1 {
+		t.Fatalf("expected 1 Microdata item, got %d", len(data.Microdata))
+	}
+	person := data.Microdata[0]
+	if !person.Is("Person") || person.String("name") != "Ada" || person.String("url") != "https://example.com/ada" {
+		t.Fatalf("unexpected person %v", person.Properties)
+	}
+	if person.String("birthDate") != "1815-12-10" || person.String("jobTitle") != "Mathematician" {
+		t.Fatalf("unexpected person %v", person.Properties)
+	}
+	addresses := person.Items("address")
+	if len(addresses) != 1 || addresses[0].String("addressLocality") != "London" {
+		t.Fatalf("unexpected address %v", addresses)
+	}
+	if _, ok := person.Properties["addressLocality"]; ok {
+		t.Fatal("nested item property added to parent")
+	}
+
+	// RDFa
+	if len(data.RDFa) != 1 {
+		t.Fatalf("expected 1 RDFa item, got %d", len(data.RDFa))
+	}
+	event := data.RDFa[0]
+	if event.Types[0] != "https://schema.org/Event" || event.ID != "https://example.com/page#launch" {
+		t.Fatalf("unexpected event %v %s", event.Types, event.ID)
+	}
+	if event.String("name") != "Launch" || event.String("startDate") != "2020-02-02" {
+		t.Fatalf("unexpected event %v", event.Properties)
+	}
+	if places := data.Find("Place"); len(places) != 1 || places[0].String("name") != "Brussels" {
+		t.Fatalf("unexpected places %v", places)
+	}
+	if titles := data.RDFaProperties["og:title"]; len(titles) != 1 || titles[0] != "Wander" {
+		t.Fatalf("unexpected RDFa properties %v", data.RDFaProperties)
+	}
+
+	// OpenGraph
+	og := data.OpenGraph
+	if og == nil || og.Title != "Wander" || og.Type != "article" {
+		t.Fatalf("unexpected OpenGraph %v", og)
+	}
+	if len(og.Images) != 2 || og.Images[0].Width != 300 || og.Images[0].Alt != "first" || og.Images[1].URL != "https://example.com/two.png" {
+		t.Fatalf("unexpected OpenGraph images %v", og.Images)
+	}
+	if og.Properties["article:published_time"][0] != "2020-01-01T00:00:00Z" {
+		t.Fatalf("unexpected OpenGraph properties %v", og.Properties)
+	}
+
+	// Twitter
+	card := data.Twitter
+	if card == nil || card.Card != "summary_large_image" || card.Site != "@wander" || card.Image != "https://example.com/card.png" {
+		t.Fatalf("unexpected Twitter card %v", card)
+	}
+}
+
+func TestExtractJSONLDResponse(t *testing.T) {
+	data, err := structured.Extract(newResponse(t, "application/ld+json", `[{"@type": "Book", "name": "Dune"}, {"@type": "Book", "name": "Emma"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	books := data.Find("Book")
+	if len(books) != 2 || books[1].String("name") != "Emma" {
+		t.Fatalf("unexpected books %v", books)
+	}
+	if data.OpenGraph != nil || data.Twitter != nil {
+		t.Fatal("unexpected metadata")
+	}
+}
+
+func TestExtractMicrodataItemrefCycles(t *testing.T) {
+	pages := []string{
+		`<div itemscope itemref="b b"></div><div id="b" itemscope itemprop="x" itemref="b b"></div>`,
+		`<div id="a" itemscope itemref="b"></div><div id="b" itemprop="x" itemscope itemref="a"><span itemprop="name">b</span></div>`,
+	}
+	for _, page := range pages {
+		done := make(chan *structured.Data)
+		go func() {
+			data, _ := structured.Extract(newResponse(t, "text/html", page))
+			done <- data
+		}()
+
+		select {
+		case data := <-done:
+			if len(data.Microdata) != 1 {
+				t.Fatalf("expected 1 Microdata item, got %d", len(data.Microdata))
+			}
+			nested := data.Microdata[0].Items("x")
+			if len(nested) != 1 {
+				t.Fatalf("expected 1 nested item, got %d", len(nested))
+			}
+			if len(nested[0].Items("x")) != 0 {
+				t.Fatal("cyclic item read again")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("extraction did not finish for %s", page)
+		}
+	}
+}